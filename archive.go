@@ -231,12 +231,15 @@ func ZipExtractFile(archiveFilePath string, destinationPath string) (err error)
 		}
 	}()
 
+	return zipExtract(&archive.Reader, destinationPath)
+}
+
+// zipExtract extracts the files of the zip archive to a given path.
+func zipExtract(archive *zip.Reader, destinationPath string) (err error) {
 	for _, f := range archive.File {
 		filePath := filepath.Join(destinationPath, f.Name)
 		if !strings.HasPrefix(filePath, filepath.Clean(destinationPath)+string(os.PathSeparator)) {
-			log.Println("invalid file path")
-
-			return
+			return fmt.Errorf("zip contained invalid name error %q", f.Name)
 		}
 		if f.FileInfo().IsDir() {
 			if err := os.MkdirAll(filePath, os.ModePerm); err != nil {
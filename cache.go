@@ -9,12 +9,180 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"sync"
 )
 
 // CacheObjectType defines a unique type for a cache object.
 // This type should be descriptive to the object that is cached.
 type CacheObjectType string
 
+// Cache defines a storage for cache objects.
+type Cache interface {
+	// ObjectRead reads data with the given unique identifier and type from the cache.
+	ObjectRead(identifier string, cacheObjectType CacheObjectType, data any) (exists bool, err error)
+	// ObjectWrite write data with the given unique identifier and type to the cache.
+	// The meta data will be stored as a human-readable data to identify the cache object.
+	ObjectWrite(identifier string, cacheObjectType CacheObjectType, data any, meta map[string]string) (err error)
+}
+
+// FilesystemCache holds a cache which stores its objects in a directory.
+type FilesystemCache struct {
+	// cachePath holds the directory of the cache.
+	cachePath string
+}
+
+var _ Cache = (*FilesystemCache)(nil)
+
+// NewFilesystemCache returns a cache which stores its objects in the given directory.
+func NewFilesystemCache(cachePath string) *FilesystemCache {
+	return &FilesystemCache{
+		cachePath: cachePath,
+	}
+}
+
+// ObjectRead reads data with the given unique identifier and type from the cache.
+func (c *FilesystemCache) ObjectRead(identifier string, cacheObjectType CacheObjectType, data any) (exists bool, err error) {
+	return CacheObjectRead(c.cachePath, identifier, cacheObjectType, data)
+}
+
+// ObjectWrite write data with the given unique identifier and type to the cache.
+func (c *FilesystemCache) ObjectWrite(identifier string, cacheObjectType CacheObjectType, data any, meta map[string]string) (err error) {
+	return CacheObjectWrite(c.cachePath, identifier, cacheObjectType, data, meta)
+}
+
+// InMemoryCache holds a concurrency-safe cache which stores its objects in memory.
+// Objects are stored encoded, so reading an object always returns a copy of the written data.
+type InMemoryCache struct {
+	lock sync.RWMutex
+
+	// objects holds the encoded objects by their relative object path and type.
+	objects map[string][]byte
+	// metas holds the meta data of the objects by their relative object path and type.
+	metas map[string]map[string]string
+}
+
+var _ Cache = (*InMemoryCache)(nil)
+
+// NewInMemoryCache returns a cache which stores its objects in memory.
+func NewInMemoryCache() *InMemoryCache {
+	return &InMemoryCache{
+		objects: map[string][]byte{},
+		metas:   map[string]map[string]string{},
+	}
+}
+
+// ObjectRead reads data with the given unique identifier and type from the cache.
+func (c *InMemoryCache) ObjectRead(identifier string, cacheObjectType CacheObjectType, data any) (exists bool, err error) {
+	c.lock.RLock()
+	raw, ok := c.objects[inMemoryCacheKey(identifier, cacheObjectType)]
+	c.lock.RUnlock()
+	if !ok {
+		return false, nil
+	}
+
+	if err := gob.NewDecoder(bytes.NewReader(raw)).Decode(data); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// ObjectWrite write data with the given unique identifier and type to the cache.
+func (c *InMemoryCache) ObjectWrite(identifier string, cacheObjectType CacheObjectType, data any, meta map[string]string) (err error) {
+	var raw bytes.Buffer
+	if err := gob.NewEncoder(&raw).Encode(data); err != nil {
+		return err
+	}
+
+	var metaCopy map[string]string
+	if meta != nil {
+		metaCopy = make(map[string]string, len(meta))
+		for k, v := range meta {
+			metaCopy[k] = v
+		}
+	}
+
+	key := inMemoryCacheKey(identifier, cacheObjectType)
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.objects[key] = raw.Bytes()
+	c.metas[key] = metaCopy
+
+	return nil
+}
+
+// Meta returns the meta data of the cache object with the given unique identifier and type.
+func (c *InMemoryCache) Meta(identifier string, cacheObjectType CacheObjectType) (meta map[string]string, exists bool) {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+
+	meta, exists = c.metas[inMemoryCacheKey(identifier, cacheObjectType)]
+
+	return meta, exists
+}
+
+// inMemoryCacheKey returns the key of an object within an in-memory cache.
+func inMemoryCacheKey(identifier string, cacheObjectType CacheObjectType) string {
+	return filepath.Join(cacheObjectPath(identifier), string(cacheObjectType))
+}
+
+// LayeredCache holds a cache which consists of multiple caches that are queried in order, e.g. a fast in-memory cache in front of a filesystem cache.
+// Objects are written to all layers. An object that is read from a later layer is written to all earlier layers, without its meta data.
+type LayeredCache struct {
+	// layers holds the caches ordered from the first to query to the last to query.
+	layers []Cache
+}
+
+var _ Cache = (*LayeredCache)(nil)
+
+// NewLayeredCache returns a cache which queries the given caches in order.
+func NewLayeredCache(layers ...Cache) *LayeredCache {
+	return &LayeredCache{
+		layers: layers,
+	}
+}
+
+// NewInMemoryFilesystemCache returns a cache which holds objects in memory in front of a cache stored in the given directory.
+func NewInMemoryFilesystemCache(cachePath string) *LayeredCache {
+	return NewLayeredCache(NewInMemoryCache(), NewFilesystemCache(cachePath))
+}
+
+// ObjectRead reads data with the given unique identifier and type from the cache.
+func (c *LayeredCache) ObjectRead(identifier string, cacheObjectType CacheObjectType, data any) (exists bool, err error) {
+	for i, layer := range c.layers {
+		exists, err := layer.ObjectRead(identifier, cacheObjectType, data)
+		if err != nil {
+			return false, err
+		} else if !exists {
+			continue
+		}
+
+		for _, missingLayer := range c.layers[:i] {
+			if err := missingLayer.ObjectWrite(identifier, cacheObjectType, data, nil); err != nil {
+				return true, err
+			}
+		}
+
+		return true, nil
+	}
+
+	return false, nil
+}
+
+// ObjectWrite write data with the given unique identifier and type to the cache.
+func (c *LayeredCache) ObjectWrite(identifier string, cacheObjectType CacheObjectType, data any, meta map[string]string) (err error) {
+	// Write the last layer first, so an object is never only in the faster layers if writing fails.
+	for i := len(c.layers) - 1; i >= 0; i-- {
+		if err := c.layers[i].ObjectWrite(identifier, cacheObjectType, data, meta); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
 // CacheObjectWrite write data with the given unique identifier and type to the cache.
 // The meta data will be written as a human-readable data to identify the cache object.
 func CacheObjectWrite(cachePath string, identifier string, cacheObjectType CacheObjectType, data any, meta map[string]string) (err error) {
@@ -33,3 +33,87 @@ func TestCacheObject(t *testing.T) {
 		assert.Equal(t, dataToBeCached, dataToBeRead)
 	}
 }
+
+func TestCacheBackends(t *testing.T) {
+	type testCase struct {
+		Name string
+
+		Cache func(t *testing.T) Cache
+	}
+
+	validate := func(t *testing.T, tc *testCase) {
+		t.Run(tc.Name, func(t *testing.T) {
+			cache := tc.Cache(t)
+
+			dataToBeCached := map[string]string{
+				"A": "1",
+				"B": "2",
+			}
+			identifier := "some-identifier"
+			typ := CacheObjectType("some-type")
+
+			{
+				var dataToBeRead map[string]string
+				exists, err := cache.ObjectRead(identifier, typ, &dataToBeRead)
+				assert.NoError(t, err)
+				assert.False(t, exists)
+			}
+
+			assert.NoError(t, cache.ObjectWrite(identifier, typ, dataToBeCached, map[string]string{"meta": "data"}))
+			dataToBeCached["C"] = "3" // Changing the data after writing must not change the cached object.
+
+			{
+				var dataToBeRead map[string]string
+				exists, err := cache.ObjectRead(identifier, typ, &dataToBeRead)
+				assert.NoError(t, err)
+				assert.True(t, exists)
+				assert.Equal(t, map[string]string{"A": "1", "B": "2"}, dataToBeRead)
+			}
+		})
+	}
+
+	validate(t, &testCase{
+		Name: "Filesystem",
+
+		Cache: func(t *testing.T) Cache {
+			return NewFilesystemCache(t.TempDir())
+		},
+	})
+	validate(t, &testCase{
+		Name: "In-memory",
+
+		Cache: func(t *testing.T) Cache {
+			return NewInMemoryCache()
+		},
+	})
+	validate(t, &testCase{
+		Name: "In-memory in front of filesystem",
+
+		Cache: func(t *testing.T) Cache {
+			return NewInMemoryFilesystemCache(t.TempDir())
+		},
+	})
+}
+
+func TestLayeredCacheFillsEarlierLayers(t *testing.T) {
+	temporaryPath := t.TempDir()
+	identifier := "some-identifier"
+	typ := CacheObjectType("some-type")
+
+	assert.NoError(t, CacheObjectWrite(temporaryPath, identifier, typ, "some data", nil))
+
+	memory := NewInMemoryCache()
+	cache := NewLayeredCache(memory, NewFilesystemCache(temporaryPath))
+
+	var dataToBeRead string
+	exists, err := cache.ObjectRead(identifier, typ, &dataToBeRead)
+	assert.NoError(t, err)
+	assert.True(t, exists)
+	assert.Equal(t, "some data", dataToBeRead)
+
+	dataToBeRead = ""
+	exists, err = memory.ObjectRead(identifier, typ, &dataToBeRead)
+	assert.NoError(t, err)
+	assert.True(t, exists)
+	assert.Equal(t, "some data", dataToBeRead)
+}
@@ -0,0 +1,112 @@
+package osutil
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+)
+
+// CaptureStream defines a standard stream that is captured.
+type CaptureStream int
+
+const (
+	// CaptureStreamStdout indicates STDOUT.
+	CaptureStreamStdout = CaptureStream(1)
+	// CaptureStreamStderr indicates STDERR.
+	CaptureStreamStderr = CaptureStream(2)
+)
+
+// String returns the name of the stream.
+func (s CaptureStream) String() string {
+	switch s {
+	case CaptureStreamStdout:
+		return "stdout"
+	case CaptureStreamStderr:
+		return "stderr"
+	default:
+		return "unknown"
+	}
+}
+
+// CaptureOptions holds options for capturing the standard streams of a function call.
+type CaptureOptions struct {
+	// WithCGo also captures STDOUT and STDERR of C by swapping the file descriptors of the process instead of only the Go streams.
+	WithCGo bool
+	// RecordChunks records every chunk of captured output tagged with its stream, so the order of STDOUT and STDERR output can be reconstructed.
+	RecordChunks bool
+
+	// Stdout receives every chunk of STDOUT output as soon as it is read.
+	Stdout io.Writer
+	// Stderr receives every chunk of STDERR output as soon as it is read.
+	// The writers of both streams are never called concurrently, so the same writer can be used for both streams.
+	Stderr io.Writer
+	// Tee writes every chunk additionally to the original stream, e.g. to still show the output in the terminal while capturing it.
+	Tee bool
+
+	// MaxBufferedSize holds the maximum number of bytes that are kept of each stream and of the recorded chunks. If more output is captured, the oldest output is dropped.
+	// Zero means no limit.
+	MaxBufferedSize int
+
+	// Log redirects the output of the default logger of the "log" package to the captured STDERR, since the logger keeps writing to the original STDERR otherwise. This includes the default logger of "log/slog" as long as no other default handler has been set.
+	Log bool
+	// SlogHandlers holds handlers of "log/slog" whose output is redirected to the captured STDERR.
+	SlogHandlers []*CapturableSlogHandler
+}
+
+// CapturedChunk holds a chunk of output that has been read from a captured stream.
+type CapturedChunk struct {
+	// Stream holds the stream the chunk was written to.
+	Stream CaptureStream
+	// Data holds the output of the chunk.
+	Data []byte
+}
+
+// CapturedOutput holds the output of separately captured standard streams.
+type CapturedOutput struct {
+	// Stdout holds the output written to STDOUT.
+	Stdout []byte
+	// Stderr holds the output written to STDERR.
+	Stderr []byte
+	// Chunks holds the output of both streams in the order it has been read, if chunks are recorded.
+	// The order between the streams is only exact up to chunks that have been written in quick succession, since both streams are read concurrently.
+	Chunks []CapturedChunk
+
+	// Truncated is set if older output has been dropped because of the maximum buffered size.
+	Truncated bool
+}
+
+// Combined returns the output of both streams interleaved in the order of the recorded chunks.
+func (o *CapturedOutput) Combined() []byte {
+	var b bytes.Buffer
+	for _, chunk := range o.Chunks {
+		b.Write(chunk.Data)
+	}
+
+	return b.Bytes()
+}
+
+// CapturePanicError holds a panic that happened during a captured function call.
+type CapturePanicError struct {
+	// Output holds the output that has been captured until the panic.
+	Output []byte
+	// Value holds the value the function call panicked with.
+	Value any
+	// Stack holds the stack trace of the panic.
+	Stack []byte
+}
+
+var _ error = (*CapturePanicError)(nil)
+
+// Error returns the panic with the captured output and the stack trace.
+func (e *CapturePanicError) Error() string {
+	return fmt.Sprintf("captured call panicked: %v\n\nCaptured output:\n%s\n\nStack trace:\n%s", e.Value, e.Output, e.Stack)
+}
+
+// Unwrap returns the value of the panic if it is an error.
+func (e *CapturePanicError) Unwrap() error {
+	if err, ok := e.Value.(error); ok {
+		return err
+	}
+
+	return nil
+}
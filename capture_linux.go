@@ -9,230 +9,622 @@ package osutil
 import "C"
 
 import (
-	"bytes"
+	"errors"
 	"io"
 	"os"
 	"os/signal"
+	"runtime/debug"
 	"sync"
 	"syscall"
 )
 
 var lockStdFileDescriptorsSwapping sync.Mutex
-var lockStdFileWithCGoDescriptorsSwapping sync.Mutex
 
 // Capture captures stderr and stdout of a given function call.
+// If the function call panics, the panic is returned as "*CapturePanicError" together with the output that has been captured until then.
 func Capture(call func()) (output []byte, err error) {
-	lockStdFileDescriptorsSwapping.Lock()
+	o, err := capture(call, CaptureOptions{}, true)
+	if o == nil {
+		return nil, err
+	}
 
-	r, w, err := os.Pipe()
-	if err != nil {
+	return o.Stdout, err
+}
+
+// CaptureWithCGo captures stderr and stdout as well as stderr and stdout of C of a given function call.
+// Calls can be nested, in which case the inner call captures only the output of its own function call.
+// If the function call panics, the panic is returned as "*CapturePanicError" together with the output that has been captured until then.
+func CaptureWithCGo(call func()) (output []byte, err error) {
+	o, err := capture(call, CaptureOptions{
+		WithCGo: true,
+	}, true)
+	if o == nil {
 		return nil, err
 	}
-	defer func() {
-		e := r.Close()
-		if e != nil {
-			err = e
-		}
-		if w != nil {
-			e = w.Close()
+
+	return o.Stdout, err
+}
+
+// CaptureWithOptions captures stderr and stdout of a given function call separately.
+// If the function call panics, the panic is returned as "*CapturePanicError" together with the output that has been captured until then.
+func CaptureWithOptions(call func(), options CaptureOptions) (output *CapturedOutput, err error) {
+	return capture(call, options, false)
+}
+
+// captureCollector collects the output that is read from captured streams.
+type captureCollector struct {
+	lock sync.Mutex
+
+	// recordChunks records every chunk that is read.
+	recordChunks bool
+	// maxBufferedSize holds the maximum number of bytes to keep of each stream and of the recorded chunks.
+	maxBufferedSize int
+	// output holds the collected output.
+	output CapturedOutput
+	// chunksSize holds the number of bytes of the recorded chunks.
+	chunksSize int
+	// err holds the first error that happened while reading.
+	err error
+
+	// writeLock serializes writing to the writers of the streams.
+	writeLock sync.Mutex
+	// writers holds the writers that receive every chunk of a stream.
+	writers map[CaptureStream][]io.Writer
+
+	// readers holds the reading goroutines.
+	readers sync.WaitGroup
+}
+
+// read starts reading the given stream until it is closed.
+func (c *captureCollector) read(stream CaptureStream, reader io.Reader) {
+	c.readers.Add(1)
+	go func() {
+		defer c.readers.Done()
+
+		buffer := make([]byte, 32*1024)
+		for {
+			n, err := reader.Read(buffer)
+			if n > 0 {
+				c.collect(stream, buffer[:n])
+			}
 			if err != nil {
-				err = e
+				if !errors.Is(err, io.EOF) {
+					c.setError(err)
+				}
+
+				return
 			}
 		}
 	}()
+}
 
-	originalStdErr, originalStdOut := os.Stderr, os.Stdout
-	release := func() {
-		os.Stderr, os.Stdout = originalStdErr, originalStdOut
+// setError remembers the given error if no other error happened before.
+func (c *captureCollector) setError(err error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if c.err == nil {
+		c.err = err
 	}
-	defer func() {
-		lockStdFileDescriptorsSwapping.Lock()
+}
 
-		if w != nil {
-			release()
+// collect adds the given data of a stream to the collected output.
+func (c *captureCollector) collect(stream CaptureStream, data []byte) {
+	c.writeLock.Lock()
+	writers := c.writers[stream]
+	for i, w := range writers {
+		if w == nil {
+			continue
 		}
 
-		lockStdFileDescriptorsSwapping.Unlock()
-	}()
-	os.Stderr, os.Stdout = w, w
-
-	out := make(chan []byte)
-	go func() {
-		defer func() {
-			// If there is a panic in the function call, copying from "r" does not work anymore.
-			_ = recover()
-		}()
+		if _, err := w.Write(data); err != nil {
+			// Keep on reading so the captured call does not block on a full pipe, but stop writing to the failing writer.
+			c.setError(err)
+			writers[i] = nil
+		}
+	}
+	c.writeLock.Unlock()
 
-		var b bytes.Buffer
+	c.lock.Lock()
+	defer c.lock.Unlock()
 
-		_, err := io.Copy(&b, r)
-		if err != nil {
-			panic(err)
+	switch stream {
+	case CaptureStreamStdout:
+		c.output.Stdout = c.truncate(append(c.output.Stdout, data...))
+	case CaptureStreamStderr:
+		c.output.Stderr = c.truncate(append(c.output.Stderr, data...))
+	}
+	if c.recordChunks {
+		c.output.Chunks = append(c.output.Chunks, CapturedChunk{
+			Stream: stream,
+			Data:   append([]byte(nil), data...),
+		})
+		c.chunksSize += len(data)
+
+		if c.maxBufferedSize > 0 {
+			for c.chunksSize > c.maxBufferedSize {
+				c.output.Truncated = true
+
+				overflow := c.chunksSize - c.maxBufferedSize
+				if first := &c.output.Chunks[0]; len(first.Data) > overflow {
+					first.Data = first.Data[overflow:]
+					c.chunksSize -= overflow
+				} else {
+					c.output.Chunks = c.output.Chunks[1:]
+					c.chunksSize -= len(first.Data)
+				}
+			}
 		}
+	}
+}
 
-		out <- b.Bytes()
-	}()
-
-	lockStdFileDescriptorsSwapping.Unlock()
+// truncate drops the oldest output of the given data that exceeds the maximum buffered size.
+func (c *captureCollector) truncate(data []byte) []byte {
+	if c.maxBufferedSize <= 0 || len(data) <= c.maxBufferedSize {
+		return data
+	}
+	c.output.Truncated = true
 
-	call()
+	return data[len(data)-c.maxBufferedSize:]
+}
 
-	lockStdFileDescriptorsSwapping.Lock()
+// capturePipe holds a pipe that receives a captured stream.
+type capturePipe struct {
+	// stream holds the stream which is received.
+	stream CaptureStream
+	// reader holds the reading end of the pipe.
+	reader *os.File
+	// writer holds the writing end of the pipe.
+	writer *os.File
+}
 
-	err = w.Close()
-	if err != nil {
-		lockStdFileDescriptorsSwapping.Unlock()
+// capturePipes holds the pipes of a capture.
+type capturePipes []*capturePipe
 
-		return nil, err
+// newCapturePipes creates a pipe for every stream. If the streams are merged, one pipe is shared by both streams to keep the order of their output and the output is collected as STDOUT.
+func newCapturePipes(merged bool) (pipes capturePipes, err error) {
+	streams := []CaptureStream{CaptureStreamStdout}
+	if !merged {
+		streams = append(streams, CaptureStreamStderr)
 	}
-	w = nil
 
-	release()
+	for _, stream := range streams {
+		r, w, err := os.Pipe()
+		if err != nil {
+			return nil, errors.Join(err, pipes.closeReaders(), pipes.closeWriters())
+		}
+
+		pipes = append(pipes, &capturePipe{
+			stream: stream,
+			reader: r,
+			writer: w,
+		})
+	}
 
-	lockStdFileDescriptorsSwapping.Unlock()
+	return pipes, nil
+}
 
-	return <-out, err
+// stdout returns the writer for STDOUT.
+func (ps capturePipes) stdout() *os.File {
+	return ps[0].writer
 }
 
-// CaptureWithCGo captures stderr and stdout as well as stderr and stdout of C of a given function call.
-// Currently this function cannot be nested.
-func CaptureWithCGo(call func()) (output []byte, err error) {
-	// FIXME At the moment this function does not work with nested calls (recursively). This might be because of the signal handler or the way we clone the file descriptors. I really do not know. Since we do not need recursive calls right now we can postpone this for later. https://$INTERNAL/symflower/symflower/-/issues/85
-	lockStdFileWithCGoDescriptorsSwapping.Lock()
-	defer lockStdFileWithCGoDescriptorsSwapping.Unlock()
+// stderr returns the writer for STDERR.
+func (ps capturePipes) stderr() *os.File {
+	return ps[len(ps)-1].writer
+}
 
-	lockStdFileDescriptorsSwapping.Lock()
+// closeWriters closes all writing ends of the pipes that are still open.
+func (ps capturePipes) closeWriters() (err error) {
+	for _, p := range ps {
+		if p.writer == nil {
+			continue
+		}
 
-	r, w, err := os.Pipe()
-	if err != nil {
-		return nil, err
+		if e := p.writer.Close(); e != nil {
+			err = errors.Join(err, e)
+		}
+		p.writer = nil
 	}
-	defer func() {
-		e := r.Close()
-		if e != nil {
-			err = e
+
+	return err
+}
+
+// closeReaders closes all reading ends of the pipes that are still open.
+func (ps capturePipes) closeReaders() (err error) {
+	for _, p := range ps {
+		if p.reader == nil {
+			continue
 		}
-		if w != nil {
-			e = w.Close()
-			if err != nil {
-				err = e
-			}
+
+		if e := p.reader.Close(); e != nil {
+			err = errors.Join(err, e)
 		}
-	}()
+		p.reader = nil
+	}
 
-	originalStdout, err := syscall.Dup(syscall.Stdout)
+	return err
+}
+
+// capture captures stderr and stdout of a given function call.
+func capture(call func(), options CaptureOptions, merged bool) (output *CapturedOutput, err error) {
+	lockStdFileDescriptorsSwapping.Lock()
+
+	pipes, err := newCapturePipes(merged)
 	if err != nil {
 		lockStdFileDescriptorsSwapping.Unlock()
 
 		return nil, err
 	}
-	originalStderr, err := syscall.Dup(syscall.Stderr)
+
+	var swap interface {
+		// originals returns writers for the original streams, which stay usable until "closeOriginals" is called.
+		originals() (stdout io.Writer, stderr io.Writer, err error)
+		// closeOriginals closes the writers for the original streams.
+		closeOriginals() error
+		// release restores the original streams.
+		release() error
+		// finish flushes the captured streams.
+		finish() error
+	}
+	if options.WithCGo {
+		swap, err = swapFileDescriptors(pipes)
+	} else {
+		swap = swapGoStreams(pipes)
+	}
 	if err != nil {
 		lockStdFileDescriptorsSwapping.Unlock()
 
-		return nil, err
+		return nil, errors.Join(err, pipes.closeWriters(), pipes.closeReaders())
+	}
+
+	collector := &captureCollector{
+		recordChunks:    options.RecordChunks,
+		maxBufferedSize: options.MaxBufferedSize,
+		writers: map[CaptureStream][]io.Writer{
+			CaptureStreamStdout: nil,
+			CaptureStreamStderr: nil,
+		},
+	}
+	if options.Stdout != nil {
+		collector.writers[CaptureStreamStdout] = append(collector.writers[CaptureStreamStdout], options.Stdout)
+	}
+	if options.Stderr != nil {
+		collector.writers[CaptureStreamStderr] = append(collector.writers[CaptureStreamStderr], options.Stderr)
+	}
+	if options.Tee {
+		originalStdout, originalStderr, err := swap.originals()
+		if err != nil {
+			err = errors.Join(err, swap.release(), pipes.closeWriters(), pipes.closeReaders())
+			lockStdFileDescriptorsSwapping.Unlock()
+
+			return nil, err
+		}
+		collector.writers[CaptureStreamStdout] = append(collector.writers[CaptureStreamStdout], originalStdout)
+		collector.writers[CaptureStreamStderr] = append(collector.writers[CaptureStreamStderr], originalStderr)
+	}
+	for _, p := range pipes {
+		collector.read(p.stream, p.reader)
 	}
-	release := func() {
-		if e := syscall.Dup2(originalStdout, syscall.Stdout); e != nil {
-			err = e
+
+	releaseLoggers := redirectLoggers(options, pipes.stderr())
+
+	restored := false
+	restore := func() (err error) {
+		lockStdFileDescriptorsSwapping.Lock()
+		releaseLoggers()
+		err = swap.finish()
+		if e := pipes.closeWriters(); e != nil {
+			err = errors.Join(err, e)
 		}
-		if e := syscall.Close(originalStdout); e != nil {
-			err = e
+		if e := swap.release(); e != nil {
+			err = errors.Join(err, e)
 		}
-		if e := syscall.Dup2(originalStderr, syscall.Stderr); e != nil {
-			err = e
+		restored = true
+		lockStdFileDescriptorsSwapping.Unlock()
+
+		// The original streams are only closed after all captured output has been forwarded to them.
+		collector.readers.Wait()
+		if e := pipes.closeReaders(); e != nil {
+			err = errors.Join(err, e)
 		}
-		if e := syscall.Close(originalStderr); e != nil {
-			err = e
+		if e := swap.closeOriginals(); e != nil {
+			err = errors.Join(err, e)
 		}
+
+		return err
 	}
 	defer func() {
-		lockStdFileDescriptorsSwapping.Lock()
+		if !restored {
+			// The call did not return, e.g. because it called "runtime.Goexit". Restore everything so the caller can continue.
+			_ = restore()
+		}
+	}()
+
+	lockStdFileDescriptorsSwapping.Unlock()
+
+	panicErr := capturePanic(call)
 
-		if w != nil {
-			release()
+	err = restore()
+	if collector.err != nil {
+		err = errors.Join(err, collector.err)
+	}
+	if panicErr != nil {
+		if merged {
+			panicErr.Output = collector.output.Stdout
+		} else if options.RecordChunks {
+			panicErr.Output = collector.output.Combined()
+		} else {
+			panicErr.Output = append(append([]byte(nil), collector.output.Stdout...), collector.output.Stderr...)
 		}
 
-		lockStdFileDescriptorsSwapping.Unlock()
+		return &collector.output, errors.Join(panicErr, err)
+	} else if err != nil {
+		return nil, err
+	}
+
+	return &collector.output, nil
+}
+
+// capturePanic calls the given function and returns a panic of the function as error.
+func capturePanic(call func()) (panicErr *CapturePanicError) {
+	defer func() {
+		if r := recover(); r != nil {
+			panicErr = &CapturePanicError{
+				Value: r,
+				Stack: debug.Stack(),
+			}
+		}
 	}()
 
+	call()
+
+	return nil
+}
+
+// goStreamsSwap holds the original Go streams while they are swapped.
+type goStreamsSwap struct {
+	originalStdout *os.File
+	originalStderr *os.File
+}
+
+// swapGoStreams points the Go streams to the given pipes.
+func swapGoStreams(pipes capturePipes) *goStreamsSwap {
+	s := &goStreamsSwap{
+		originalStdout: os.Stdout,
+		originalStderr: os.Stderr,
+	}
+	os.Stdout, os.Stderr = pipes.stdout(), pipes.stderr()
+
+	return s
+}
+
+// originals returns writers for the original streams.
+func (s *goStreamsSwap) originals() (stdout io.Writer, stderr io.Writer, err error) {
+	return s.originalStdout, s.originalStderr, nil
+}
+
+// closeOriginals does nothing, since the original streams are owned by the process.
+func (s *goStreamsSwap) closeOriginals() error {
+	return nil
+}
+
+// release restores the original streams.
+func (s *goStreamsSwap) release() error {
+	os.Stdout, os.Stderr = s.originalStdout, s.originalStderr
+
+	return nil
+}
+
+// finish flushes the captured streams.
+func (s *goStreamsSwap) finish() error {
+	return nil
+}
+
+// fileDescriptorsSwaps holds the stack of active swaps of the file descriptors of STDOUT and STDERR. The last swap is the one that currently receives the output.
+// The stack is guarded by "lockStdFileDescriptorsSwapping".
+var fileDescriptorsSwaps []*fileDescriptorsSwap
+
+// stopFileDescriptorsSwapsSignalHandler stops the signal handler that is active as long as there are file descriptor swaps.
+var stopFileDescriptorsSwapsSignalHandler func()
+
+// fileDescriptorsSwap holds the file descriptors that have been active before a swap.
+type fileDescriptorsSwap struct {
+	// previousStdout holds a duplicate of STDOUT before the swap, or -1 if it has been closed.
+	previousStdout int
+	// previousStderr holds a duplicate of STDERR before the swap, or -1 if it has been closed.
+	previousStderr int
+
+	// originalStdout holds a duplicate of STDOUT before the swap that output is forwarded to, or -1 if there is none.
+	originalStdout int
+	// originalStderr holds a duplicate of STDERR before the swap that output is forwarded to, or -1 if there is none.
+	originalStderr int
+}
+
+// swapFileDescriptors points the file descriptors of STDOUT and STDERR to the given pipes.
+// Swaps can be nested. The file descriptors that are active before a swap, e.g. the pipes of an outer swap, are restored when the swap is released.
+func swapFileDescriptors(pipes capturePipes) (swap *fileDescriptorsSwap, err error) {
+	previousStdout, err := syscall.Dup(syscall.Stdout)
+	if err != nil {
+		return nil, err
+	}
+	previousStderr, err := syscall.Dup(syscall.Stderr)
+	if err != nil {
+		return nil, errors.Join(err, syscall.Close(previousStdout))
+	}
+	s := &fileDescriptorsSwap{
+		previousStdout: previousStdout,
+		previousStderr: previousStderr,
+		originalStdout: -1,
+		originalStderr: -1,
+	}
+
+	if len(fileDescriptorsSwaps) == 0 {
+		startFileDescriptorsSwapsSignalHandler()
+	}
+	fileDescriptorsSwaps = append(fileDescriptorsSwaps, s)
+
+	if err := syscall.Dup2(int(pipes.stdout().Fd()), syscall.Stdout); err != nil {
+		return nil, errors.Join(err, s.release())
+	}
+	if err := syscall.Dup2(int(pipes.stderr().Fd()), syscall.Stderr); err != nil {
+		return nil, errors.Join(err, s.release())
+	}
+
+	return s, nil
+}
+
+// startFileDescriptorsSwapsSignalHandler starts the signal handler that cleans up the file descriptors of all swaps.
+func startFileDescriptorsSwapsSignalHandler() {
 	// WORKAROUND Since Go 1.10 `go test` can hang (randomly) if a subprocess has another subprocess that got the original STDOUT/STDERR if the defined STDOUT/STDERR are not a file from the Go side. This is a somewhat incomplete description of this bug. More details can be found here https://github.com/golang/go/issues/24050 and here https://github.com/golang/go/issues/23019. This bug occurs randomly not just with `go test` but anywhere the same APIs are used. However, the only time this happens with the current function is when a parent process kills the currently running process but not when we have, e.g. a panic in the call we are capturing. This is already handled by the defer calls. Since the exiting is not handled, we have to set up a signal handler to take care of the cleanup.
 
 	exitSignalHandler := make(chan bool)
 	sigs := make(chan os.Signal, 10)
 	signal.Notify(sigs, syscall.SIGCHLD)
-	defer func() {
+	stopFileDescriptorsSwapsSignalHandler = func() {
 		signal.Stop(sigs)
-		exitSignalHandler <- true
-	}()
+		close(exitSignalHandler)
+	}
 
 	go func() {
 		select {
 		case <-sigs:
-			_ = syscall.Close(originalStdout)
-			_ = syscall.Close(originalStderr)
+			lockStdFileDescriptorsSwapping.Lock()
+			defer lockStdFileDescriptorsSwapping.Unlock()
+
+			for _, s := range fileDescriptorsSwaps {
+				if s.previousStdout >= 0 {
+					_ = syscall.Close(s.previousStdout)
+					s.previousStdout = -1
+				}
+				if s.previousStderr >= 0 {
+					_ = syscall.Close(s.previousStderr)
+					s.previousStderr = -1
+				}
+			}
 		case <-exitSignalHandler:
 		}
 	}()
+}
 
-	if e := syscall.Dup2(int(w.Fd()), syscall.Stdout); e != nil {
-		lockStdFileDescriptorsSwapping.Unlock()
+// originals returns writers for the file descriptors that have been active before the swap.
+// The writers use their own duplicates of the file descriptors, since the file descriptors of the swap are already closed when it is released, while captured output might still be forwarded.
+func (s *fileDescriptorsSwap) originals() (stdout io.Writer, stderr io.Writer, err error) {
+	if s.originalStdout, err = syscall.Dup(s.previousStdout); err != nil {
+		s.originalStdout = -1
 
-		return nil, e
+		return nil, nil, err
 	}
-	if e := syscall.Dup2(int(w.Fd()), syscall.Stderr); e != nil {
-		lockStdFileDescriptorsSwapping.Unlock()
+	if s.originalStderr, err = syscall.Dup(s.previousStderr); err != nil {
+		s.originalStderr = -1
 
-		return nil, e
+		return nil, nil, errors.Join(err, s.closeOriginals())
 	}
 
-	out := make(chan []byte)
-	go func() {
-		defer func() {
-			// If there is a panic in the function call, copying from "r" does not work anymore.
-			_ = recover()
-		}()
+	return fileDescriptorWriter(s.originalStdout), fileDescriptorWriter(s.originalStderr), nil
+}
 
-		var b bytes.Buffer
+// closeOriginals closes the duplicates of the file descriptors that output is forwarded to.
+func (s *fileDescriptorsSwap) closeOriginals() (err error) {
+	for _, fd := range []*int{&s.originalStdout, &s.originalStderr} {
+		if *fd < 0 {
+			continue
+		}
 
-		_, err := io.Copy(&b, r)
-		if err != nil {
-			panic(err)
+		if e := syscall.Close(*fd); e != nil {
+			err = errors.Join(err, e)
 		}
+		*fd = -1
+	}
 
-		out <- b.Bytes()
-	}()
+	return err
+}
 
-	lockStdFileDescriptorsSwapping.Unlock()
+// release restores the file descriptors that have been active before the swap.
+// If the swap is not the last swap, e.g. because captures of different goroutines ended in a different order than they started, the following swap restores the file descriptors of this swap instead.
+func (s *fileDescriptorsSwap) release() (err error) {
+	index := -1
+	for i, swap := range fileDescriptorsSwaps {
+		if swap == s {
+			index = i
 
-	call()
+			break
+		}
+	}
+	if index < 0 {
+		return nil
+	}
 
-	lockStdFileDescriptorsSwapping.Lock()
+	if index == len(fileDescriptorsSwaps)-1 {
+		for _, fds := range [][2]int{
+			{s.previousStdout, syscall.Stdout},
+			{s.previousStderr, syscall.Stderr},
+		} {
+			previous, current := fds[0], fds[1]
+			if previous < 0 {
+				// The previous file descriptor is gone, so at least end the pipe.
+				if e := syscall.Close(current); e != nil {
+					err = errors.Join(err, e)
+				}
+
+				continue
+			}
 
-	C.fflush(C.stderr)
-	C.fflush(C.stdout)
+			if e := syscall.Dup2(previous, current); e != nil {
+				err = errors.Join(err, e)
+			}
+			if e := syscall.Close(previous); e != nil {
+				err = errors.Join(err, e)
+			}
+		}
+	} else {
+		// The following swap holds duplicates of the pipes of this swap, which are replaced by the file descriptors this swap has to restore.
+		following := fileDescriptorsSwaps[index+1]
+		for _, previous := range []int{following.previousStdout, following.previousStderr} {
+			if previous < 0 {
+				continue
+			}
 
-	if err = w.Close(); err != nil {
-		lockStdFileDescriptorsSwapping.Unlock()
+			if e := syscall.Close(previous); e != nil {
+				err = errors.Join(err, e)
+			}
+		}
+		following.previousStdout, following.previousStderr = s.previousStdout, s.previousStderr
+	}
+	s.previousStdout, s.previousStderr = -1, -1
 
-		return nil, err
+	fileDescriptorsSwaps = append(fileDescriptorsSwaps[:index], fileDescriptorsSwaps[index+1:]...)
+	if len(fileDescriptorsSwaps) == 0 {
+		stopFileDescriptorsSwapsSignalHandler()
 	}
-	w = nil
 
-	if err = syscall.Close(syscall.Stdout); err != nil {
-		lockStdFileDescriptorsSwapping.Unlock()
+	return err
+}
 
-		return nil, err
-	}
-	if err = syscall.Close(syscall.Stderr); err != nil {
-		lockStdFileDescriptorsSwapping.Unlock()
+// finish flushes the captured streams.
+func (s *fileDescriptorsSwap) finish() (err error) {
+	C.fflush(C.stderr)
+	C.fflush(C.stdout)
 
-		return nil, err
-	}
+	return nil
+}
 
-	release()
+// fileDescriptorWriter writes to a raw file descriptor without taking ownership of it.
+type fileDescriptorWriter int
 
-	lockStdFileDescriptorsSwapping.Unlock()
+// Write writes the given data to the file descriptor.
+func (w fileDescriptorWriter) Write(data []byte) (n int, err error) {
+	for n < len(data) {
+		m, err := syscall.Write(int(w), data[n:])
+		if err != nil {
+			if err == syscall.EINTR {
+				continue
+			}
+
+			return n, err
+		}
+		n += m
+	}
 
-	return <-out, err
+	return n, nil
 }
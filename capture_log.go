@@ -0,0 +1,146 @@
+package osutil
+
+import (
+	"context"
+	"io"
+	"log"
+	"log/slog"
+	"sync"
+)
+
+// CapturableSlogHandler holds a "slog.Handler" whose output can be redirected by capturing, since handlers usually keep writing to the stream they have been created with.
+type CapturableSlogHandler struct {
+	// state holds the state that is shared with all derived handlers.
+	state *capturableSlogHandlerState
+	// derive holds the operations that derive this handler from the base handler, e.g. added attributes and groups.
+	derive []func(handler slog.Handler) slog.Handler
+
+	// lock guards the cached handler.
+	lock sync.Mutex
+	// handler holds the cached handler for the current writer.
+	handler slog.Handler
+	// generation holds the generation of the writer the cached handler has been created for.
+	generation uint64
+}
+
+var _ slog.Handler = (*CapturableSlogHandler)(nil)
+
+// capturableSlogHandlerState holds the state of a capturable handler that is shared with all its derived handlers.
+type capturableSlogHandlerState struct {
+	lock sync.RWMutex
+
+	// newHandler creates a handler that writes to the given writer.
+	newHandler func(writer io.Writer) slog.Handler
+	// writers holds the stack of writers, with the current writer last.
+	writers []io.Writer
+	// generation is increased whenever the current writer changes.
+	generation uint64
+}
+
+// NewCapturableSlogHandler returns a handler that writes to the given writer with handlers of the given constructor, e.g. "slog.NewTextHandler", until its output is redirected by capturing.
+func NewCapturableSlogHandler(writer io.Writer, newHandler func(writer io.Writer) slog.Handler) *CapturableSlogHandler {
+	return &CapturableSlogHandler{
+		state: &capturableSlogHandlerState{
+			newHandler: newHandler,
+			writers:    []io.Writer{writer},
+		},
+	}
+}
+
+// redirect writes the output of the handler and all its derived handlers to the given writer until the returned function is called.
+func (h *CapturableSlogHandler) redirect(writer io.Writer) (release func()) {
+	h.state.lock.Lock()
+	defer h.state.lock.Unlock()
+
+	h.state.writers = append(h.state.writers, writer)
+	h.state.generation++
+
+	return func() {
+		h.state.lock.Lock()
+		defer h.state.lock.Unlock()
+
+		for i := len(h.state.writers) - 1; i > 0; i-- {
+			if h.state.writers[i] == writer {
+				h.state.writers = append(h.state.writers[:i], h.state.writers[i+1:]...)
+
+				break
+			}
+		}
+		h.state.generation++
+	}
+}
+
+// current returns the handler for the current writer.
+func (h *CapturableSlogHandler) current() slog.Handler {
+	h.state.lock.RLock()
+	defer h.state.lock.RUnlock()
+
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	if h.handler == nil || h.generation != h.state.generation {
+		handler := h.state.newHandler(h.state.writers[len(h.state.writers)-1])
+		for _, derive := range h.derive {
+			handler = derive(handler)
+		}
+		h.handler = handler
+		h.generation = h.state.generation
+	}
+
+	return h.handler
+}
+
+// Enabled reports whether the handler handles records at the given level.
+func (h *CapturableSlogHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.current().Enabled(ctx, level)
+}
+
+// Handle handles the given record.
+func (h *CapturableSlogHandler) Handle(ctx context.Context, record slog.Record) error {
+	return h.current().Handle(ctx, record)
+}
+
+// WithAttrs returns a new handler whose attributes consist of both the receiver's attributes and the given attributes.
+func (h *CapturableSlogHandler) WithAttrs(attributes []slog.Attr) slog.Handler {
+	return h.with(func(handler slog.Handler) slog.Handler {
+		return handler.WithAttrs(attributes)
+	})
+}
+
+// WithGroup returns a new handler with the given group appended to the receiver's existing groups.
+func (h *CapturableSlogHandler) WithGroup(name string) slog.Handler {
+	return h.with(func(handler slog.Handler) slog.Handler {
+		return handler.WithGroup(name)
+	})
+}
+
+// with returns a new handler that shares the state of the receiver and is derived with the given operation.
+func (h *CapturableSlogHandler) with(derive func(handler slog.Handler) slog.Handler) *CapturableSlogHandler {
+	return &CapturableSlogHandler{
+		state:  h.state,
+		derive: append(append([]func(handler slog.Handler) slog.Handler(nil), h.derive...), derive),
+	}
+}
+
+// redirectLoggers redirects the loggers of the given capture options to the given writer until the returned function is called.
+func redirectLoggers(options CaptureOptions, writer io.Writer) (release func()) {
+	var releases []func()
+
+	if options.Log {
+		logger := log.Default()
+		originalWriter := logger.Writer()
+		logger.SetOutput(writer)
+		releases = append(releases, func() {
+			logger.SetOutput(originalWriter)
+		})
+	}
+	for _, handler := range options.SlogHandlers {
+		releases = append(releases, handler.redirect(writer))
+	}
+
+	return func() {
+		for i := len(releases) - 1; i >= 0; i-- {
+			releases[i]()
+		}
+	}
+}
@@ -6,3 +6,8 @@ package osutil
 func Capture(call func()) (output []byte, err error) {
 	panic("not implemented") // WORKAROUND Implement this function for MacOS and Windows when it is actual needed. Until then we can cross-compile even if the function is only mentioned in a package. https://$INTERNAL/symflower/symflower/-/issues/3575
 }
+
+// CaptureWithOptions captures stderr and stdout of a given function call separately.
+func CaptureWithOptions(call func(), options CaptureOptions) (output *CapturedOutput, err error) {
+	panic("not implemented") // WORKAROUND Implement this function for MacOS and Windows when it is actual needed. Until then we can cross-compile even if the function is only mentioned in a package. https://$INTERNAL/symflower/symflower/-/issues/3575
+}
@@ -0,0 +1,307 @@
+//go:build linux && cgo
+
+package osutil
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"log/slog"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCapture(t *testing.T) {
+	assert.NoError(t, SetRLimitFiles(10, func(limit uint64) {
+		// Use at least one more file descriptor than our current limit, so we make sure that there are no file descriptor leaks.
+		for i := 0; i <= int(limit); i++ {
+			testCapture(t)
+		}
+	}))
+}
+func TestCaptureRecursive(t *testing.T) {
+	assert.NoError(t, SetRLimitFiles(10, func(limit uint64) {
+		// Use at least one more file descriptor than our current limit, so we make sure that there are no file descriptor leaks.
+		for i := 0; i <= int(limit); i++ {
+			out, err := Capture(func() {
+				_, err := fmt.Fprintf(os.Stdout, "1")
+				require.NoError(t, err)
+				_, err = fmt.Fprintf(os.Stderr, "2")
+				require.NoError(t, err)
+				_, err = fmt.Fprintf(os.Stdout, "3")
+				require.NoError(t, err)
+				_, err = fmt.Fprintf(os.Stdout, "4")
+				require.NoError(t, err)
+
+				out, err := Capture(func() {
+					_, err := fmt.Fprintf(os.Stdout, "A")
+					require.NoError(t, err)
+					_, err = fmt.Fprintf(os.Stderr, "B")
+					require.NoError(t, err)
+					_, err = fmt.Fprintf(os.Stdout, "C")
+					require.NoError(t, err)
+					_, err = fmt.Fprintf(os.Stderr, "D")
+					require.NoError(t, err)
+
+				})
+				assert.NoError(t, err)
+
+				assert.Equal(t, "ABCD", string(out))
+			})
+			assert.NoError(t, err)
+
+			assert.Equal(t, "1234", string(out))
+		}
+	}))
+}
+
+func TestCaptureWithPanic(t *testing.T) {
+	assert.NoError(t, SetRLimitFiles(10, func(limit uint64) {
+		// Use at least one more file descriptor than our current limit, so we make sure that there are no file descriptor leaks.
+		for i := 0; i <= int(limit); i++ {
+			out, err := Capture(func() {
+				fmt.Println("abc")
+
+				panic("stop")
+			})
+
+			var panicErr *CapturePanicError
+			require.ErrorAs(t, err, &panicErr)
+			assert.Equal(t, "stop", panicErr.Value)
+			assert.Equal(t, "abc\n", string(panicErr.Output))
+			assert.Contains(t, string(panicErr.Stack), "capture_test.go")
+			assert.Equal(t, "abc\n", string(out))
+		}
+	}))
+}
+func TestCaptureWithHugeOutput(t *testing.T) {
+	// Huge output to test buffering and piping.
+
+	out, err := Capture(func() {
+		for i := 0; i < 1024; i++ {
+			fmt.Println(strings.Repeat("a", 1024))
+		}
+	})
+	assert.NoError(t, err)
+
+	assert.NotEqual(t, bytes.Repeat([]byte("a"), 1024*1024), out)
+}
+
+func TestCaptureWithCGo(t *testing.T) {
+	assert.NoError(t, SetRLimitFiles(10, func(limit uint64) {
+		// Use at least one more file descriptor than our current limit, so we make sure that there are no file descriptor leaks.
+		for i := 0; i <= int(limit); i++ {
+			testCaptureWithCGo(t)
+		}
+	}))
+}
+
+func TestCaptureWithCGoRecursive(t *testing.T) {
+	assert.NoError(t, SetRLimitFiles(20, func(limit uint64) {
+		// Use at least one more file descriptor than our current limit, so we make sure that there are no file descriptor leaks.
+		for i := 0; i <= int(limit); i++ {
+			testCaptureWithCGoRecursive(t)
+		}
+	}))
+}
+
+func TestCaptureWithCGoWithPanic(t *testing.T) {
+	assert.NoError(t, SetRLimitFiles(10, func(limit uint64) {
+		// Use at least one more file descriptor than our current limit, so we make sure that there are no file descriptor leaks.
+		for i := 0; i <= int(limit); i++ {
+			out, err := CaptureWithCGo(func() {
+				fmt.Println("abc")
+
+				panic("stop")
+			})
+
+			var panicErr *CapturePanicError
+			require.ErrorAs(t, err, &panicErr)
+			assert.Equal(t, "stop", panicErr.Value)
+			assert.Equal(t, "abc\n", string(panicErr.Output))
+			assert.Equal(t, "abc\n", string(out))
+		}
+	}))
+}
+func TestCaptureWithCGoWithHugeOutput(t *testing.T) {
+	// Huge output to test buffering and piping.
+
+	out, err := CaptureWithCGo(func() {
+		for i := 0; i < 1024; i++ {
+			fmt.Println(strings.Repeat("a", 1024))
+		}
+	})
+	assert.NoError(t, err)
+
+	assert.NotEqual(t, bytes.Repeat([]byte("a"), 1024*1024), out)
+}
+
+func TestCaptureWithOptions(t *testing.T) {
+	assert.NoError(t, SetRLimitFiles(20, func(limit uint64) {
+		// Use at least one more file descriptor than our current limit, so we make sure that there are no file descriptor leaks.
+		for i := 0; i <= int(limit); i++ {
+			for _, withCGo := range []bool{false, true} {
+				output, err := CaptureWithOptions(func() {
+					_, err := fmt.Fprint(os.Stdout, "1")
+					require.NoError(t, err)
+					_, err = fmt.Fprint(os.Stderr, "2")
+					require.NoError(t, err)
+					_, err = fmt.Fprint(os.Stdout, "3")
+					require.NoError(t, err)
+				}, CaptureOptions{
+					WithCGo:      withCGo,
+					RecordChunks: true,
+				})
+				require.NoError(t, err)
+
+				assert.Equal(t, "13", string(output.Stdout))
+				assert.Equal(t, "2", string(output.Stderr))
+				assert.ElementsMatch(t, []byte("123"), output.Combined())
+				for _, chunk := range output.Chunks {
+					if chunk.Stream == CaptureStreamStderr {
+						assert.Equal(t, "2", string(chunk.Data))
+					}
+				}
+			}
+		}
+	}))
+}
+
+func TestCaptureWithOptionsStreaming(t *testing.T) {
+	for _, withCGo := range []bool{false, true} {
+		var stdoutLines []string
+		stdoutWriter := NewLineWriter(func(line string) {
+			stdoutLines = append(stdoutLines, line)
+		})
+		var stderrLines []string
+		stderrWriter := NewLineWriter(func(line string) {
+			stderrLines = append(stderrLines, line)
+		})
+		output, err := CaptureWithOptions(func() {
+			fmt.Println("abc")
+			fmt.Fprintln(os.Stderr, "def")
+			fmt.Print("ghi")
+		}, CaptureOptions{
+			WithCGo: withCGo,
+			Stdout:  stdoutWriter,
+			Stderr:  stderrWriter,
+		})
+		require.NoError(t, err)
+		assert.NoError(t, stdoutWriter.Close())
+		assert.NoError(t, stderrWriter.Close())
+
+		assert.Equal(t, "abc\nghi", string(output.Stdout))
+		assert.Equal(t, "def\n", string(output.Stderr))
+		assert.Equal(t, []string{"abc", "ghi"}, stdoutLines)
+		assert.Equal(t, []string{"def"}, stderrLines)
+	}
+}
+
+func TestCaptureWithOptionsMaxBufferedSize(t *testing.T) {
+	output, err := CaptureWithOptions(func() {
+		for i := 0; i < 1024; i++ {
+			fmt.Println(strings.Repeat("a", 1023))
+		}
+		fmt.Print("end")
+	}, CaptureOptions{
+		RecordChunks:    true,
+		MaxBufferedSize: 10,
+	})
+	require.NoError(t, err)
+
+	assert.True(t, output.Truncated)
+	assert.Equal(t, "aaaaaa\nend", string(output.Stdout))
+	assert.Equal(t, "aaaaaa\nend", string(output.Combined()))
+}
+
+func TestCaptureWithOptionsWithPanic(t *testing.T) {
+	output, err := CaptureWithOptions(func() {
+		fmt.Println("abc")
+		fmt.Fprintln(os.Stderr, "def")
+
+		panic(errors.New("stop"))
+	}, CaptureOptions{})
+
+	var panicErr *CapturePanicError
+	require.ErrorAs(t, err, &panicErr)
+	assert.EqualError(t, errors.Unwrap(panicErr), "stop")
+	assert.Equal(t, "abc\ndef\n", string(panicErr.Output))
+	assert.Equal(t, "abc\n", string(output.Stdout))
+	assert.Equal(t, "def\n", string(output.Stderr))
+
+	// The standard streams must be usable again.
+	out, err := Capture(func() {
+		fmt.Println("ghi")
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "ghi\n", string(out))
+}
+
+func TestCaptureWithOptionsLoggers(t *testing.T) {
+	var original bytes.Buffer
+	handler := NewCapturableSlogHandler(&original, func(writer io.Writer) slog.Handler {
+		return slog.NewTextHandler(writer, &slog.HandlerOptions{
+			ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+				if a.Key == slog.TimeKey {
+					return slog.Attr{}
+				}
+
+				return a
+			},
+		})
+	})
+	logger := slog.New(handler).With("key", "value")
+
+	output, err := CaptureWithOptions(func() {
+		log.Print("log")
+		logger.Info("slog")
+	}, CaptureOptions{
+		Log:          true,
+		SlogHandlers: []*CapturableSlogHandler{handler},
+	})
+	require.NoError(t, err)
+
+	assert.Contains(t, string(output.Stderr), "log\n")
+	assert.Contains(t, string(output.Stderr), "level=INFO msg=slog key=value\n")
+	assert.Empty(t, original.String())
+
+	logger.Info("after")
+	assert.Equal(t, "level=INFO msg=after key=value\n", original.String())
+}
+
+func TestCaptureWithOptionsTee(t *testing.T) {
+	for _, withCGo := range []bool{false, true} {
+		t.Run(fmt.Sprintf("WithCGo=%t", withCGo), func(t *testing.T) {
+			var inner *CapturedOutput
+			// The outer capture stands in for the original streams, so we can assert what the inner capture forwards to them.
+			outer, err := CaptureWithOptions(func() {
+				var err error
+				inner, err = CaptureWithOptions(func() {
+					_, err := fmt.Fprint(os.Stdout, "out")
+					require.NoError(t, err)
+					_, err = fmt.Fprint(os.Stderr, "err")
+					require.NoError(t, err)
+				}, CaptureOptions{
+					WithCGo: withCGo,
+					Tee:     true,
+				})
+				require.NoError(t, err)
+			}, CaptureOptions{
+				WithCGo: withCGo,
+			})
+			require.NoError(t, err)
+
+			require.NotNil(t, inner)
+			assert.Equal(t, "out", string(inner.Stdout))
+			assert.Equal(t, "err", string(inner.Stderr))
+			assert.Equal(t, "out", string(outer.Stdout))
+			assert.Equal(t, "err", string(outer.Stderr))
+		})
+	}
+}
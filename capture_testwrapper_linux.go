@@ -39,3 +39,22 @@ func testCaptureWithCGo(t *testing.T) {
 	assert.Contains(t, string(out), "Go")
 	assert.Contains(t, string(out), "C")
 }
+
+func testCaptureWithCGoRecursive(t *testing.T) {
+	out, err := CaptureWithCGo(func() {
+		fmt.Println("Go outer")
+
+		out, err := CaptureWithCGo(func() {
+			fmt.Println("Go inner")
+			C.printSomething()
+		})
+		assert.NoError(t, err)
+
+		assert.Equal(t, "Go inner\nC\n", string(out))
+
+		fmt.Println("Go outer again")
+	})
+	assert.NoError(t, err)
+
+	assert.Equal(t, "Go outer\nGo outer again\n", string(out))
+}
@@ -1,23 +1,114 @@
 package osutil
 
 import (
+	"bytes"
 	"crypto/md5"
 	"crypto/sha256"
+	"crypto/sha512"
 	"encoding/hex"
+	"errors"
 	"fmt"
+	"hash"
 	"io"
 	"os"
+	pathpkg "path"
 	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
 	"syscall"
+
+	"golang.org/x/crypto/blake2b"
+)
+
+// ChecksumAlgorithm defines a hash algorithm for checksums.
+type ChecksumAlgorithm string
+
+const (
+	// ChecksumAlgorithmMD5 indicates MD5, which should only be used for compatibility with existing checksums.
+	ChecksumAlgorithmMD5 = ChecksumAlgorithm("md5")
+	// ChecksumAlgorithmSHA256 indicates SHA-256.
+	ChecksumAlgorithmSHA256 = ChecksumAlgorithm("sha256")
+	// ChecksumAlgorithmSHA512 indicates SHA-512.
+	ChecksumAlgorithmSHA512 = ChecksumAlgorithm("sha512")
+	// ChecksumAlgorithmBLAKE2b256 indicates BLAKE2b with a 256-bit digest.
+	ChecksumAlgorithmBLAKE2b256 = ChecksumAlgorithm("blake2b-256")
+	// ChecksumAlgorithmBLAKE2b512 indicates BLAKE2b with a 512-bit digest.
+	ChecksumAlgorithmBLAKE2b512 = ChecksumAlgorithm("blake2b-512")
 )
 
+// ErrUnknownChecksumAlgorithm indicates that a checksum algorithm is not supported.
+var ErrUnknownChecksumAlgorithm = errors.New("unknown checksum algorithm")
+
+// New returns a new hash of the algorithm.
+func (a ChecksumAlgorithm) New() (h hash.Hash, err error) {
+	switch a {
+	case ChecksumAlgorithmMD5:
+		return md5.New(), nil
+	case ChecksumAlgorithmSHA256:
+		return sha256.New(), nil
+	case ChecksumAlgorithmSHA512:
+		return sha512.New(), nil
+	case ChecksumAlgorithmBLAKE2b256:
+		return blake2b.New256(nil)
+	case ChecksumAlgorithmBLAKE2b512:
+		return blake2b.New512(nil)
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrUnknownChecksumAlgorithm, string(a))
+	}
+}
+
+// ChecksumOptions holds options for computing checksums.
+type ChecksumOptions struct {
+	// Algorithm holds the hash algorithm. Defaults to SHA-256.
+	Algorithm ChecksumAlgorithm
+	// Workers holds the number of files that are hashed in parallel. Defaults to the number of CPUs.
+	Workers int
+
+	// SymlinksAsTarget hashes symlinks by their target instead of the contents of the file they point to. This includes symlinks to directories and broken symlinks, which are skipped otherwise.
+	SymlinksAsTarget bool
+	// IncludeMode includes the mode of files, e.g. the executable bits, in the checksum.
+	IncludeMode bool
+	// IncludeEmptyDirectories includes directories without any files in the checksum.
+	IncludeEmptyDirectories bool
+	// Excludes holds patterns of "path.Match" for files and directories that are not part of the checksum, e.g. ".git" or the checksum file itself. A pattern matches either the name or the slash-separated path relative to the checksummed path.
+	Excludes []string
+
+	// StatCache holds an optional cache of file digests, so only files whose size, modification time, inode or change time changed are hashed again.
+	StatCache *ChecksumStatCache
+}
+
+// algorithm returns the hash algorithm of the options.
+func (o ChecksumOptions) algorithm() ChecksumAlgorithm {
+	if o.Algorithm == "" {
+		return ChecksumAlgorithmSHA256
+	}
+
+	return o.Algorithm
+}
+
+// workers returns the number of files that are hashed in parallel.
+func (o ChecksumOptions) workers() int {
+	if o.Workers <= 0 {
+		return runtime.NumCPU()
+	}
+
+	return o.Workers
+}
+
 // WriteChecksumForPath computes a checksum of a file or directory and writes it to the given file.
 func WriteChecksumForPath(path string, checksumFile string) error {
-	digest, err := ChecksumForPath(path)
+	return WriteChecksumForPathWithOptions(path, checksumFile, ChecksumOptions{})
+}
+
+// WriteChecksumForPathWithOptions computes a checksum of a file or directory with the given options and writes it together with its algorithm to the given file.
+func WriteChecksumForPathWithOptions(path string, checksumFile string, options ChecksumOptions) error {
+	digest, err := ChecksumForPathWithOptions(path, options)
 	if err != nil {
-		panic(err)
+		return err
 	}
-	if err := os.WriteFile(checksumFile, []byte(fmt.Sprintf("%x\n", digest)), 0644); err != nil {
+	if err := os.WriteFile(checksumFile, []byte(fmt.Sprintf("%s:%x\n", options.algorithm(), digest)), 0644); err != nil {
 		return err
 	}
 
@@ -25,64 +116,439 @@ func WriteChecksumForPath(path string, checksumFile string) error {
 }
 
 // ValidateChecksumForPath computes a checksum of a file or directory and returns an error if it does not match the checksum stored in the given file.
+// The algorithm is taken from the checksum file. Checksum files without an algorithm are validated with MD5.
 func ValidateChecksumForPath(path string, checksumFile string) (valid bool, err error) {
+	return ValidateChecksumForPathWithOptions(path, checksumFile, ChecksumOptions{})
+}
+
+// ValidateChecksumForPathWithOptions computes a checksum of a file or directory with the given options and returns an error if it does not match the checksum stored in the given file.
+// The algorithm is always taken from the checksum file. Checksum files without an algorithm are validated with MD5.
+func ValidateChecksumForPathWithOptions(path string, checksumFile string, options ChecksumOptions) (valid bool, err error) {
 	contents, err := os.ReadFile(checksumFile)
 	if err != nil {
 		return false, err
 	}
+	algorithm, expectedDigest, legacy, err := parseChecksumFile(contents)
+	if err != nil {
+		return false, err
+	}
 
-	digest, err := ChecksumForPath(path)
+	var digest []byte
+	if legacy {
+		digest, err = checksumForPathConcatenated(path, algorithm)
+	} else {
+		options.Algorithm = algorithm
+		digest, err = ChecksumForPathWithOptions(path, options)
+	}
 	if err != nil {
 		return false, err
 	}
 
-	return fmt.Sprintf("%x\n", digest) == string(contents), nil
+	return bytes.Equal(digest, expectedDigest), nil
+}
+
+// parseChecksumFile returns the algorithm and digest of the contents of a checksum file. Legacy checksum files have no algorithm and hold a MD5 digest of the concatenated files.
+func parseChecksumFile(contents []byte) (algorithm ChecksumAlgorithm, digest []byte, legacy bool, err error) {
+	line := strings.TrimSpace(string(contents))
+
+	algorithm = ChecksumAlgorithmMD5
+	legacy = true
+	if a, hexDigest, ok := strings.Cut(line, ":"); ok {
+		algorithm = ChecksumAlgorithm(a)
+		legacy = false
+		line = hexDigest
+	}
+	if _, err := algorithm.New(); err != nil {
+		return "", nil, false, err
+	}
+
+	digest, err = hex.DecodeString(line)
+	if err != nil {
+		return "", nil, false, fmt.Errorf("invalid checksum %q: %w", line, err)
+	}
+
+	return algorithm, digest, legacy, nil
 }
 
-// ChecksumForPath computes a checksum of a file or directory.
+// ChecksumForPath computes a checksum of a file or directory with the default options, i.e. with SHA-256 and files that are hashed in parallel.
+// The checksum differs from the MD5 checksums of previous versions, which are only validated for legacy checksum files. Use "ChecksumForPathWithOptions" for other algorithms.
 func ChecksumForPath(path string) (digest []byte, err error) {
-	hash := md5.New()
+	return ChecksumForPathWithOptions(path, ChecksumOptions{})
+}
+
+// checksumForPathConcatenated computes a checksum of a file or directory by hashing the relative paths and contents of all files in sequence.
+func checksumForPathConcatenated(path string, algorithm ChecksumAlgorithm) (digest []byte, err error) {
+	hash, err := algorithm.New()
+	if err != nil {
+		return nil, err
+	}
 
+	entries, err := checksumFileEntries(path, ChecksumOptions{})
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range entries {
+		skip, err := func() (skip bool, err error) {
+			file, err := os.Open(entry.path)
+			if err != nil {
+				return entry.skipOnError(err), err
+			}
+			defer func() {
+				if e := file.Close(); e != nil {
+					err = errors.Join(err, e)
+				}
+			}()
+
+			// Read the first chunk before writing anything to the hash, so symlinks to directories can still be skipped.
+			buffer := make([]byte, 32*1024)
+			n, err := file.Read(buffer)
+			if err != nil && !errors.Is(err, io.EOF) {
+				return entry.skipOnError(err), err
+			}
+
+			if _, err := hash.Write([]byte(entry.relativePathNative)); err != nil {
+				return false, err
+			}
+			if _, err := hash.Write([]byte{'\x00'}); err != nil {
+				return false, err
+			}
+			if _, err := hash.Write(buffer[:n]); err != nil {
+				return false, err
+			}
+			if _, err := io.CopyBuffer(hash, file, buffer); err != nil {
+				return false, err
+			}
+			if _, err := hash.Write([]byte{'\x00'}); err != nil {
+				return false, err
+			}
+
+			return false, nil
+		}()
+		if err != nil && !skip {
+			return nil, err
+		}
+	}
+
+	return hash.Sum(nil), nil
+}
+
+// ChecksumForPathWithOptions computes a checksum of a file or directory with the given options.
+// Files are hashed in parallel while streaming their contents. The digest is a tree hash of the relative paths and digests of all files in sorted order, so it does not depend on the order files are hashed in.
+func ChecksumForPathWithOptions(path string, options ChecksumOptions) (digest []byte, err error) {
+	entries, err := checksumHashedFileEntries(path, options)
+	if err != nil {
+		return nil, err
+	}
+
+	return checksumTree(entries, options)
+}
+
+// checksumHashedFileEntries returns all files of the given file or directory with their digests.
+func checksumHashedFileEntries(path string, options ChecksumOptions) (entries []*checksumFileEntry, err error) {
+	entries, err = checksumFileEntries(path, options)
+	if err != nil {
+		return nil, err
+	}
+	if err := checksumFileEntriesHash(entries, options.algorithm(), options.workers(), options.StatCache); err != nil {
+		return nil, err
+	}
+	if options.StatCache != nil {
+		options.StatCache.checksummed(path)
+	}
+
+	return entries, nil
+}
+
+// checksumFileKind defines the kind of an entry of a checksum.
+type checksumFileKind byte
+
+const (
+	// checksumFileKindFile indicates a file whose contents are hashed.
+	checksumFileKindFile = checksumFileKind(0)
+	// checksumFileKindSymlink indicates a symlink whose target is hashed.
+	checksumFileKindSymlink = checksumFileKind('l')
+	// checksumFileKindDirectory indicates an empty directory.
+	checksumFileKindDirectory = checksumFileKind('d')
+)
+
+// checksumFileEntry holds a file that is part of a checksum.
+type checksumFileEntry struct {
+	// path holds the path of the file.
+	path string
+	// relativePath holds the slash-separated path of the file relative to the path of the checksum.
+	relativePath string
+	// relativePathNative holds the path of the file relative to the path of the checksum with the separators of the operating system.
+	relativePathNative string
+	// info holds the file information of the file without following symlinks.
+	info os.FileInfo
+	// kind holds the kind of the entry.
+	kind checksumFileKind
+
+	// digest holds the digest of the contents of the file.
+	digest []byte
+	// skip is set if the file is not part of the checksum.
+	skip bool
+}
+
+// skipOnError returns if the file is skipped because of the given error.
+func (e *checksumFileEntry) skipOnError(err error) bool {
+	// Even though "filepath.Walk" does not recurse into symlinks, it still invokes us with symlink itself. We can still include symlinked files in the checksum computation. Skip symlinks to directories and broken symlinks instead of failing to compute a checksum.
+	if e.info.Mode()&os.ModeSymlink != 0 {
+		if pe, ok := err.(*os.PathError); ok && (pe.Err == syscall.EISDIR || pe.Err == syscall.ENOENT) {
+			e.skip = true
+
+			return true
+		}
+	}
+
+	return false
+}
+
+// checksumFileEntries returns all files of the given file or directory in lexical order.
+func checksumFileEntries(path string, options ChecksumOptions) (entries []*checksumFileEntry, err error) {
+	var directories []*checksumFileEntry
 	if err := filepath.Walk(path, func(file string, info os.FileInfo, err error) error {
 		if err != nil {
 			return err
 		}
+
+		relativePath, err := filepath.Rel(path, file)
+		if err != nil {
+			return err
+		}
+		entry := &checksumFileEntry{
+			path:               file,
+			relativePath:       filepath.ToSlash(relativePath),
+			relativePathNative: relativePath,
+			info:               info,
+		}
+
+		if relativePath != "." {
+			excluded, err := options.excluded(entry.relativePath)
+			if err != nil {
+				return err
+			} else if excluded {
+				if info.IsDir() {
+					return filepath.SkipDir
+				}
+
+				return nil
+			}
+		}
+
 		if info.IsDir() {
+			if options.IncludeEmptyDirectories {
+				entry.kind = checksumFileKindDirectory
+				directories = append(directories, entry)
+			}
+
 			return nil
 		}
+		if options.SymlinksAsTarget && info.Mode()&os.ModeSymlink != 0 {
+			entry.kind = checksumFileKindSymlink
+		}
 
-		contents, err := os.ReadFile(file)
-		if err != nil {
-			// Even though "filepath.Walk" does not recurse into symlinks, it still invokes us with symlink itself. We can still include symlinked files in the checksum computation. Skip symlinks to directories and broken symlinks instead of failing to compute a checksum.
-			if info.Mode()&os.ModeSymlink != 0 {
-				if pe, ok := err.(*os.PathError); ok && (pe.Err == syscall.EISDIR || pe.Err == syscall.ENOENT) {
-					return nil
+		entries = append(entries, entry)
+
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	if len(directories) > 0 {
+		// A directory is empty if it has no entries, including empty subdirectories, so the deepest directories have to be checked first.
+		notEmpty := map[string]bool{}
+		markParents := func(relativePath string) {
+			for relativePath != "." && relativePath != "/" {
+				relativePath = pathpkg.Dir(relativePath)
+				notEmpty[relativePath] = true
+			}
+		}
+		for _, entry := range entries {
+			markParents(entry.relativePath)
+		}
+		sort.SliceStable(directories, func(i, j int) bool {
+			return strings.Count(directories[i].relativePath, "/") > strings.Count(directories[j].relativePath, "/")
+		})
+		for _, directory := range directories {
+			if notEmpty[directory.relativePath] {
+				continue
+			}
+
+			entries = append(entries, directory)
+			markParents(directory.relativePath)
+		}
+	}
+
+	return entries, nil
+}
+
+// excluded returns if the given slash-separated relative path is excluded.
+func (o ChecksumOptions) excluded(relativePath string) (excluded bool, err error) {
+	for _, pattern := range o.Excludes {
+		for _, p := range []string{pathpkg.Base(relativePath), relativePath} {
+			matched, err := pathpkg.Match(pattern, p)
+			if err != nil {
+				return false, err
+			} else if matched {
+				return true, nil
+			}
+		}
+	}
+
+	return false, nil
+}
+
+// checksumFileEntriesHash computes the digests of the given files with the given number of workers in parallel. Digests of unchanged files are taken from the given stat cache, if there is one.
+func checksumFileEntriesHash(entries []*checksumFileEntry, algorithm ChecksumAlgorithm, workers int, statCache *ChecksumStatCache) (err error) {
+	if _, err := algorithm.New(); err != nil {
+		return err
+	}
+
+	queue := make(chan *checksumFileEntry)
+	var errLock sync.Mutex
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for entry := range queue {
+				if e := entry.hash(algorithm, statCache); e != nil {
+					errLock.Lock()
+					err = errors.Join(err, e)
+					errLock.Unlock()
 				}
 			}
+		}()
+	}
+
+	for _, entry := range entries {
+		errLock.Lock()
+		failed := err != nil
+		errLock.Unlock()
+		if failed {
+			break
+		}
+
+		queue <- entry
+	}
+	close(queue)
+	wg.Wait()
+
+	return err
+}
+
+// hash computes the digest of the entry. Digests of unchanged files are taken from the given stat cache, if there is one.
+func (e *checksumFileEntry) hash(algorithm ChecksumAlgorithm, statCache *ChecksumStatCache) (err error) {
+	hash, err := algorithm.New()
+	if err != nil {
+		return err
+	}
+
+	switch e.kind {
+	case checksumFileKindDirectory:
+		// Directories have no contents, their path is their content.
+	case checksumFileKindSymlink:
+		target, err := os.Readlink(e.path)
+		if err != nil {
+			return err
+		}
+		if _, err := hash.Write([]byte(filepath.ToSlash(target))); err != nil {
 			return err
 		}
+	default:
+		var statCacheEntry ChecksumStatCacheEntry
+		if statCache != nil {
+			digest, entry, ok := statCache.lookup(e.path, algorithm)
+			if ok {
+				e.digest = digest
 
-		relativePath, err := filepath.Rel(path, file)
+				return nil
+			}
+			statCacheEntry = entry
+			defer func() {
+				if err == nil && e.digest != nil && statCacheEntry.Algorithm != "" {
+					statCache.store(e.path, statCacheEntry, e.digest)
+				}
+			}()
+		}
+
+		file, err := os.Open(e.path)
 		if err != nil {
+			if e.skipOnError(err) {
+				return nil
+			}
+
 			return err
 		}
+		defer func() {
+			if closeErr := file.Close(); closeErr != nil {
+				err = errors.Join(err, closeErr)
+			}
+		}()
 
-		for _, data := range [][]byte{
-			[]byte(relativePath),
-			[]byte{'\x00'},
-			contents,
-			[]byte{'\x00'},
-		} {
-			if _, err := hash.Write(data); err != nil {
-				return err
+		if _, err := io.Copy(hash, file); err != nil {
+			if e.skipOnError(err) {
+				return nil
 			}
+
+			return err
 		}
+	}
+	e.digest = hash.Sum(nil)
 
-		return nil
-	}); err != nil {
+	return nil
+}
+
+// checksumTree computes the digest of the relative paths and digests of the given files in sorted order.
+func checksumTree(entries []*checksumFileEntry, options ChecksumOptions) (digest []byte, err error) {
+	hash, err := options.algorithm().New()
+	if err != nil {
 		return nil, err
 	}
 
+	sorted := make([]*checksumFileEntry, 0, len(entries))
+	for _, entry := range entries {
+		if !entry.skip {
+			sorted = append(sorted, entry)
+		}
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].relativePath < sorted[j].relativePath
+	})
+
+	for _, entry := range sorted {
+		record := [][]byte{
+			[]byte(entry.relativePath),
+			[]byte{'\x00'},
+		}
+		// Only entries that are not regular files are marked, so the digests of regular files do not depend on the options.
+		if entry.kind != checksumFileKindFile {
+			record = append(record, []byte{byte(entry.kind), '\x00'})
+		}
+		if options.IncludeMode {
+			mode := entry.info.Mode()
+			if entry.kind == checksumFileKindFile && mode&os.ModeSymlink != 0 {
+				// The contents of symlinked files are part of the checksum, so their mode should be as well.
+				info, err := os.Stat(entry.path)
+				if err != nil {
+					return nil, err
+				}
+				mode = info.Mode()
+			}
+
+			record = append(record, []byte(fmt.Sprintf("%o", uint32(mode))), []byte{'\x00'})
+		}
+		record = append(record, entry.digest, []byte{'\x00'})
+
+		for _, data := range record {
+			if _, err := hash.Write(data); err != nil {
+				return nil, err
+			}
+		}
+	}
+
 	return hash.Sum(nil), nil
 }
 
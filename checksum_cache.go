@@ -0,0 +1,173 @@
+package osutil
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// checksumStatCacheObjectType holds the cache object type of a checksum stat cache.
+const checksumStatCacheObjectType = CacheObjectType("checksum-stat-cache")
+
+// ChecksumStatCache holds digests of files together with their file system metadata, so files whose size, modification time, inode and change time did not change are not hashed again.
+type ChecksumStatCache struct {
+	lock sync.Mutex
+
+	// entries holds the cached digests by their absolute file path.
+	entries map[string]ChecksumStatCacheEntry
+	// roots holds the absolute paths that have been checksummed completely since the cache has been loaded or saved.
+	roots map[string]bool
+	// visited holds the absolute file paths that have been looked up since the cache has been loaded or saved.
+	visited map[string]bool
+	// changed is set if entries have been added since the cache has been loaded.
+	changed bool
+}
+
+// ChecksumStatCacheEntry holds the digest of a file together with its file system metadata.
+type ChecksumStatCacheEntry struct {
+	// Size holds the size of the file.
+	Size int64
+	// ModificationTime holds the modification time of the file in nanoseconds.
+	ModificationTime int64
+	// Inode holds the inode of the file, if available.
+	Inode uint64
+	// ChangeTime holds the change time of the file in nanoseconds, if available.
+	ChangeTime int64
+
+	// Algorithm holds the algorithm of the digest.
+	Algorithm ChecksumAlgorithm
+	// Digest holds the digest of the contents of the file.
+	Digest []byte
+}
+
+// NewChecksumStatCache returns an empty checksum stat cache.
+func NewChecksumStatCache() *ChecksumStatCache {
+	return &ChecksumStatCache{
+		entries: map[string]ChecksumStatCacheEntry{},
+		roots:   map[string]bool{},
+		visited: map[string]bool{},
+	}
+}
+
+// LoadChecksumStatCache loads the checksum stat cache with the given identifier from the given object cache. If there is no such cache yet, an empty cache is returned.
+func LoadChecksumStatCache(cache Cache, identifier string) (statCache *ChecksumStatCache, err error) {
+	statCache = NewChecksumStatCache()
+	if _, err := cache.ObjectRead(identifier, checksumStatCacheObjectType, &statCache.entries); err != nil {
+		return nil, err
+	}
+	if statCache.entries == nil {
+		statCache.entries = map[string]ChecksumStatCacheEntry{}
+	}
+
+	return statCache, nil
+}
+
+// Save stores the checksum stat cache with the given identifier in the given object cache, if it has been changed.
+// Entries of files within a path that has been checksummed since the cache has been loaded or saved, but that have not been visited by the checksum, are removed before, so the cache does not grow with files that have been removed or excluded. Entries of other paths are kept, so multiple paths can share a cache.
+func (c *ChecksumStatCache) Save(cache Cache, identifier string) (err error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	for absolutePath := range c.entries {
+		if !c.visited[absolutePath] && c.withinRoots(absolutePath) {
+			delete(c.entries, absolutePath)
+			c.changed = true
+		}
+	}
+	c.roots = map[string]bool{}
+	c.visited = map[string]bool{}
+
+	if !c.changed {
+		return nil
+	}
+
+	if err := cache.ObjectWrite(identifier, checksumStatCacheObjectType, c.entries, map[string]string{
+		"identifier": identifier,
+	}); err != nil {
+		return err
+	}
+	c.changed = false
+
+	return nil
+}
+
+// checksummed marks the given path as checksummed completely, so entries of files within it that have not been visited are removed when the cache is saved.
+func (c *ChecksumStatCache) checksummed(path string) {
+	absolutePath, err := filepath.Abs(path)
+	if err != nil {
+		return
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.roots[absolutePath] = true
+}
+
+// withinRoots returns if the given absolute file path is within a path that has been checksummed completely.
+func (c *ChecksumStatCache) withinRoots(absolutePath string) bool {
+	for root := range c.roots {
+		if absolutePath == root || strings.HasPrefix(absolutePath, strings.TrimSuffix(root, string(filepath.Separator))+string(filepath.Separator)) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// lookup returns the cached digest of the given file if its metadata did not change.
+func (c *ChecksumStatCache) lookup(filePath string, algorithm ChecksumAlgorithm) (digest []byte, entry ChecksumStatCacheEntry, ok bool) {
+	absolutePath, info, err := checksumStatCacheFile(filePath)
+	if err != nil {
+		return nil, entry, false
+	}
+	inode, changeTime := fileIdentity(info)
+	entry = ChecksumStatCacheEntry{
+		Size:             info.Size(),
+		ModificationTime: info.ModTime().UnixNano(),
+		Inode:            inode,
+		ChangeTime:       changeTime,
+		Algorithm:        algorithm,
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.visited[absolutePath] = true
+	cached, ok := c.entries[absolutePath]
+	if !ok || cached.Size != entry.Size || cached.ModificationTime != entry.ModificationTime || cached.Inode != entry.Inode || cached.ChangeTime != entry.ChangeTime || cached.Algorithm != entry.Algorithm {
+		return nil, entry, false
+	}
+
+	return cached.Digest, entry, true
+}
+
+// store caches the given digest of the given file with the metadata the file had before it has been hashed.
+func (c *ChecksumStatCache) store(filePath string, entry ChecksumStatCacheEntry, digest []byte) {
+	absolutePath, err := filepath.Abs(filePath)
+	if err != nil {
+		return
+	}
+	entry.Digest = digest
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.entries[absolutePath] = entry
+	c.changed = true
+}
+
+// checksumStatCacheFile returns the absolute path and the file information of the given file while following symlinks.
+func checksumStatCacheFile(filePath string) (absolutePath string, info os.FileInfo, err error) {
+	absolutePath, err = filepath.Abs(filePath)
+	if err != nil {
+		return "", nil, err
+	}
+	info, err = os.Stat(absolutePath)
+	if err != nil {
+		return "", nil, err
+	}
+
+	return absolutePath, info, nil
+}
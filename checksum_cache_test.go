@@ -0,0 +1,122 @@
+package osutil
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChecksumStatCache(t *testing.T) {
+	temporaryPath := t.TempDir()
+	filePath := filepath.Join(temporaryPath, "a")
+	require.NoError(t, os.WriteFile(filePath, []byte("a"), 0600))
+	require.NoError(t, os.WriteFile(filepath.Join(temporaryPath, "b"), []byte("b"), 0600))
+
+	expectedDigest, err := ChecksumForPathWithOptions(temporaryPath, ChecksumOptions{})
+	require.NoError(t, err)
+
+	statCache := NewChecksumStatCache()
+	digest, err := ChecksumForPathWithOptions(temporaryPath, ChecksumOptions{
+		StatCache: statCache,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, expectedDigest, digest)
+	assert.Len(t, statCache.entries, 2)
+
+	// Poison the cached digest to see that unchanged files are not hashed again.
+	absolutePath, err := filepath.Abs(filePath)
+	require.NoError(t, err)
+	entry := statCache.entries[absolutePath]
+	entry.Digest = []byte("poisoned")
+	statCache.entries[absolutePath] = entry
+	digest, err = ChecksumForPathWithOptions(temporaryPath, ChecksumOptions{
+		StatCache: statCache,
+	})
+	require.NoError(t, err)
+	assert.NotEqual(t, expectedDigest, digest)
+
+	// A different algorithm must not use the cached digest.
+	expectedDigestSHA512, err := ChecksumForPathWithOptions(temporaryPath, ChecksumOptions{
+		Algorithm: ChecksumAlgorithmSHA512,
+	})
+	require.NoError(t, err)
+	digest, err = ChecksumForPathWithOptions(temporaryPath, ChecksumOptions{
+		Algorithm: ChecksumAlgorithmSHA512,
+		StatCache: statCache,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, expectedDigestSHA512, digest)
+
+	// Modified files are hashed again.
+	modificationTime := time.Now().Add(time.Hour)
+	require.NoError(t, os.WriteFile(filePath, []byte("c"), 0600))
+	require.NoError(t, os.Chtimes(filePath, modificationTime, modificationTime))
+	expectedDigest, err = ChecksumForPathWithOptions(temporaryPath, ChecksumOptions{})
+	require.NoError(t, err)
+	digest, err = ChecksumForPathWithOptions(temporaryPath, ChecksumOptions{
+		StatCache: statCache,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, expectedDigest, digest)
+}
+
+func TestChecksumStatCacheSave(t *testing.T) {
+	temporaryPath := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(temporaryPath, "a"), []byte("a"), 0600))
+	cache := NewInMemoryCache()
+
+	statCache, err := LoadChecksumStatCache(cache, "toolchain")
+	require.NoError(t, err)
+	assert.Empty(t, statCache.entries)
+
+	expectedDigest, err := ChecksumForPathWithOptions(temporaryPath, ChecksumOptions{
+		StatCache: statCache,
+	})
+	require.NoError(t, err)
+	require.NoError(t, statCache.Save(cache, "toolchain"))
+
+	statCache, err = LoadChecksumStatCache(cache, "toolchain")
+	require.NoError(t, err)
+	assert.Len(t, statCache.entries, 1)
+	digest, err := ChecksumForPathWithOptions(temporaryPath, ChecksumOptions{
+		StatCache: statCache,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, expectedDigest, digest)
+
+	// Saving without a checksum keeps all entries.
+	statCache, err = LoadChecksumStatCache(cache, "toolchain")
+	require.NoError(t, err)
+	require.NoError(t, statCache.Save(cache, "toolchain"))
+	statCache, err = LoadChecksumStatCache(cache, "toolchain")
+	require.NoError(t, err)
+	assert.Len(t, statCache.entries, 1)
+
+	// Entries of files that are not visited anymore are removed, but entries of other checksummed paths are kept.
+	otherPath := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(otherPath, "c"), []byte("c"), 0600))
+	_, err = ChecksumForPathWithOptions(otherPath, ChecksumOptions{
+		StatCache: statCache,
+	})
+	require.NoError(t, err)
+	require.NoError(t, statCache.Save(cache, "toolchain"))
+	statCache, err = LoadChecksumStatCache(cache, "toolchain")
+	require.NoError(t, err)
+	assert.Len(t, statCache.entries, 2)
+	require.NoError(t, os.Remove(filepath.Join(temporaryPath, "a")))
+	require.NoError(t, os.WriteFile(filepath.Join(temporaryPath, "b"), []byte("b"), 0600))
+	_, err = ChecksumForPathWithOptions(temporaryPath, ChecksumOptions{
+		StatCache: statCache,
+	})
+	require.NoError(t, err)
+	require.NoError(t, statCache.Save(cache, "toolchain"))
+	statCache, err = LoadChecksumStatCache(cache, "toolchain")
+	require.NoError(t, err)
+	assert.Len(t, statCache.entries, 2)
+	assert.Contains(t, statCache.entries, filepath.Join(temporaryPath, "b"))
+	assert.Contains(t, statCache.entries, filepath.Join(otherPath, "c"))
+}
@@ -0,0 +1,18 @@
+//go:build darwin
+
+package osutil
+
+import (
+	"os"
+	"syscall"
+)
+
+// fileIdentity returns the inode and the change time in nanoseconds of the given file information.
+func fileIdentity(info os.FileInfo) (inode uint64, changeTime int64) {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return 0, 0
+	}
+
+	return uint64(stat.Ino), int64(stat.Ctimespec.Sec)*1e9 + int64(stat.Ctimespec.Nsec)
+}
@@ -0,0 +1,204 @@
+package osutil
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// ChecksumManifest holds the checksums of all files of a file or directory.
+type ChecksumManifest struct {
+	// Algorithm holds the hash algorithm of the digests.
+	Algorithm ChecksumAlgorithm `json:"algorithm"`
+	// Digest holds the hex-encoded digest of the whole file or directory as computed by "ChecksumForPathWithOptions".
+	Digest string `json:"digest"`
+	// Files holds the files sorted by their path.
+	Files []ChecksumManifestFile `json:"files"`
+}
+
+// ChecksumManifestFile holds the checksum of a single file of a manifest.
+type ChecksumManifestFile struct {
+	// Path holds the slash-separated path of the file relative to the path of the manifest.
+	Path string `json:"path"`
+	// Digest holds the hex-encoded digest of the contents of the file.
+	Digest string `json:"digest"`
+	// Size holds the size of the file in bytes.
+	Size int64 `json:"size"`
+	// Mode holds the mode of the file.
+	Mode os.FileMode `json:"mode"`
+}
+
+// ChecksumManifestDiff holds the differences between an expected and an actual manifest.
+type ChecksumManifestDiff struct {
+	// Added holds the paths of files which are not expected.
+	Added []string
+	// Removed holds the paths of files which are expected but missing.
+	Removed []string
+	// Modified holds the paths of files which have different contents.
+	Modified []string
+	// ModeChanged holds the paths of files which have a different mode.
+	ModeChanged []string
+}
+
+// Valid returns if there are no differences.
+func (d *ChecksumManifestDiff) Valid() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Modified) == 0 && len(d.ModeChanged) == 0
+}
+
+// String returns a human-readable summary of the differences.
+func (d *ChecksumManifestDiff) String() string {
+	var s strings.Builder
+	for _, group := range []struct {
+		name  string
+		paths []string
+	}{
+		{"added", d.Added},
+		{"removed", d.Removed},
+		{"modified", d.Modified},
+		{"mode changed", d.ModeChanged},
+	} {
+		for _, path := range group.paths {
+			fmt.Fprintf(&s, "%s: %s\n", group.name, path)
+		}
+	}
+
+	return s.String()
+}
+
+// ChecksumManifestForPath computes a manifest of all files of a file or directory with the given options.
+func ChecksumManifestForPath(path string, options ChecksumOptions) (manifest *ChecksumManifest, err error) {
+	entries, err := checksumHashedFileEntries(path, options)
+	if err != nil {
+		return nil, err
+	}
+	digest, err := checksumTree(entries, options)
+	if err != nil {
+		return nil, err
+	}
+
+	manifest = &ChecksumManifest{
+		Algorithm: options.algorithm(),
+		Digest:    hex.EncodeToString(digest),
+		Files:     []ChecksumManifestFile{},
+	}
+	for _, entry := range entries {
+		if entry.skip {
+			continue
+		}
+
+		info := entry.info
+		if entry.kind == checksumFileKindFile && info.Mode()&os.ModeSymlink != 0 {
+			// The contents of symlinked files are part of the checksum, so their size and mode should be as well.
+			if info, err = os.Stat(entry.path); err != nil {
+				return nil, err
+			}
+		}
+
+		manifest.Files = append(manifest.Files, ChecksumManifestFile{
+			Path:   entry.relativePath,
+			Digest: hex.EncodeToString(entry.digest),
+			Size:   info.Size(),
+			Mode:   info.Mode(),
+		})
+	}
+	sort.Slice(manifest.Files, func(i, j int) bool {
+		return manifest.Files[i].Path < manifest.Files[j].Path
+	})
+
+	return manifest, nil
+}
+
+// WriteChecksumManifestForPath computes a manifest of all files of a file or directory with the given options and writes it to the given file.
+func WriteChecksumManifestForPath(path string, manifestFile string, options ChecksumOptions) (err error) {
+	manifest, err := ChecksumManifestForPath(path, options)
+	if err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(manifest, "", "\t")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(manifestFile, append(data, '\n'), 0644); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// ReadChecksumManifest reads a manifest from the given file.
+func ReadChecksumManifest(manifestFile string) (manifest *ChecksumManifest, err error) {
+	data, err := os.ReadFile(manifestFile)
+	if err != nil {
+		return nil, err
+	}
+
+	manifest = &ChecksumManifest{}
+	if err := json.Unmarshal(data, manifest); err != nil {
+		return nil, fmt.Errorf("invalid checksum manifest %q: %w", manifestFile, err)
+	}
+	if _, err := manifest.Algorithm.New(); err != nil {
+		return nil, err
+	}
+
+	return manifest, nil
+}
+
+// ValidateChecksumManifestForPath computes a manifest of a file or directory and returns the differences to the manifest stored in the given file.
+// The algorithm is always taken from the manifest file.
+func ValidateChecksumManifestForPath(path string, manifestFile string, options ChecksumOptions) (diff *ChecksumManifestDiff, err error) {
+	expected, err := ReadChecksumManifest(manifestFile)
+	if err != nil {
+		return nil, err
+	}
+
+	options.Algorithm = expected.Algorithm
+	actual, err := ChecksumManifestForPath(path, options)
+	if err != nil {
+		return nil, err
+	}
+
+	return expected.Diff(actual), nil
+}
+
+// Diff returns the differences between the manifest as the expected manifest and the given actual manifest.
+func (m *ChecksumManifest) Diff(actual *ChecksumManifest) (diff *ChecksumManifestDiff) {
+	diff = &ChecksumManifestDiff{}
+
+	expectedFiles := make(map[string]ChecksumManifestFile, len(m.Files))
+	for _, file := range m.Files {
+		expectedFiles[file.Path] = file
+	}
+	actualFiles := make(map[string]ChecksumManifestFile, len(actual.Files))
+	for _, file := range actual.Files {
+		actualFiles[file.Path] = file
+
+		expectedFile, ok := expectedFiles[file.Path]
+		if !ok {
+			diff.Added = append(diff.Added, file.Path)
+
+			continue
+		}
+
+		if expectedFile.Digest != file.Digest || expectedFile.Size != file.Size || m.Algorithm != actual.Algorithm {
+			diff.Modified = append(diff.Modified, file.Path)
+		}
+		if expectedFile.Mode != file.Mode {
+			diff.ModeChanged = append(diff.ModeChanged, file.Path)
+		}
+	}
+	for _, file := range m.Files {
+		if _, ok := actualFiles[file.Path]; !ok {
+			diff.Removed = append(diff.Removed, file.Path)
+		}
+	}
+
+	for _, paths := range [][]string{diff.Added, diff.Removed, diff.Modified, diff.ModeChanged} {
+		sort.Strings(paths)
+	}
+
+	return diff
+}
@@ -0,0 +1,49 @@
+package osutil
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateChecksumManifestForPath(t *testing.T) {
+	if IsWindows() {
+		t.SkipNow() // TODO Make this test case compatible with Windows file modes.
+	}
+
+	temporaryPath := t.TempDir()
+	checkTearError(t, os.WriteFile(filepath.Join(temporaryPath, "unchanged"), []byte("unchanged"), 0600))
+	checkTearError(t, os.WriteFile(filepath.Join(temporaryPath, "modified"), []byte("original"), 0600))
+	checkTearError(t, os.WriteFile(filepath.Join(temporaryPath, "removed"), []byte("removed"), 0600))
+	checkTearError(t, WriteFile(filepath.Join(temporaryPath, "directory", "mode changed"), []byte("mode changed")))
+	manifestFile := filepath.Join(t.TempDir(), "manifest.json")
+
+	require.NoError(t, WriteChecksumManifestForPath(temporaryPath, manifestFile, ChecksumOptions{}))
+
+	diff, err := ValidateChecksumManifestForPath(temporaryPath, manifestFile, ChecksumOptions{})
+	require.NoError(t, err)
+	assert.True(t, diff.Valid())
+
+	checkTearError(t, os.WriteFile(filepath.Join(temporaryPath, "modified"), []byte("modified"), 0600))
+	checkTearError(t, os.Remove(filepath.Join(temporaryPath, "removed")))
+	checkTearError(t, os.WriteFile(filepath.Join(temporaryPath, "added"), []byte("added"), 0600))
+	checkTearError(t, os.Chmod(filepath.Join(temporaryPath, "directory", "mode changed"), 0755))
+
+	diff, err = ValidateChecksumManifestForPath(temporaryPath, manifestFile, ChecksumOptions{})
+	require.NoError(t, err)
+	assert.False(t, diff.Valid())
+	assert.Equal(t, &ChecksumManifestDiff{
+		Added:       []string{"added"},
+		Removed:     []string{"removed"},
+		Modified:    []string{"modified"},
+		ModeChanged: []string{"directory/mode changed"},
+	}, diff)
+
+	manifest, err := ReadChecksumManifest(manifestFile)
+	require.NoError(t, err)
+	assert.Equal(t, ChecksumAlgorithmSHA256, manifest.Algorithm)
+	assert.Len(t, manifest.Files, 4)
+}
@@ -0,0 +1,13 @@
+//go:build !linux && !darwin
+
+package osutil
+
+import (
+	"os"
+)
+
+// fileIdentity returns the inode and the change time in nanoseconds of the given file information.
+func fileIdentity(info os.FileInfo) (inode uint64, changeTime int64) {
+	// WORKAROUND The file information of other platforms, e.g. Windows, has no inodes and change times, so only the size and modification time are used to identify changes.
+	return 0, 0
+}
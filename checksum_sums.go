@@ -0,0 +1,238 @@
+package osutil
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// SHA256SumsFileName holds the common name of a file with SHA-256 checksums of the files of its directory.
+const SHA256SumsFileName = "SHA256SUMS"
+
+// ErrChecksumNotFound indicates that a checksums file does not contain a checksum for a file.
+var ErrChecksumNotFound = errors.New("checksum not found")
+
+// ChecksumsEntry holds an entry of a checksums file in the format of "sha256sum" and "shasum".
+type ChecksumsEntry struct {
+	// Digest holds the hex-encoded digest of the file.
+	Digest string
+	// Path holds the path of the file as written in the checksums file. The path is empty if the checksums file only holds a digest.
+	Path string
+	// Binary is set if the file has been read in binary mode, which is marked with a "*" in front of the path.
+	Binary bool
+}
+
+// ParseChecksums parses the given contents of a checksums file in the format of "sha256sum" and "shasum", i.e. lines of "<digest>  <path>" or "<digest> *<path>".
+// A checksums file which only holds a digest, e.g. a ".sha256" file that is written by "ChecksumsSHA256ForFiles", results in an entry without a path.
+func ParseChecksums(contents []byte) (entries []ChecksumsEntry, err error) {
+	scanner := bufio.NewScanner(bytes.NewReader(contents))
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+
+		// File names with backslashes or line breaks are escaped and their line is prefixed with a backslash.
+		escaped := strings.HasPrefix(line, "\\")
+		if escaped {
+			line = line[1:]
+		}
+
+		digest, rest, hasPath := strings.Cut(line, " ")
+		if _, err := hex.DecodeString(digest); err != nil || digest == "" {
+			return nil, fmt.Errorf("invalid checksum in line %d: %q", lineNumber, line)
+		}
+		entry := ChecksumsEntry{
+			Digest: strings.ToLower(digest),
+		}
+		if hasPath {
+			if len(rest) < 2 || (rest[0] != ' ' && rest[0] != '*') {
+				return nil, fmt.Errorf("invalid checksum format in line %d: %q", lineNumber, line)
+			}
+			entry.Binary = rest[0] == '*'
+			entry.Path = rest[1:]
+			if escaped {
+				entry.Path = strings.NewReplacer(`\\`, `\`, `\n`, "\n", `\r`, "\r").Replace(entry.Path)
+			}
+		}
+
+		entries = append(entries, entry)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return entries, nil
+}
+
+// FormatChecksums returns the given entries in the format of "sha256sum".
+func FormatChecksums(entries []ChecksumsEntry) []byte {
+	var b bytes.Buffer
+	for _, entry := range entries {
+		p := entry.Path
+		if strings.ContainsAny(p, "\\\n\r") {
+			b.WriteByte('\\')
+			p = strings.NewReplacer(`\`, `\\`, "\n", `\n`, "\r", `\r`).Replace(p)
+		}
+
+		b.WriteString(entry.Digest)
+		if entry.Binary {
+			b.WriteString(" *")
+		} else {
+			b.WriteString("  ")
+		}
+		b.WriteString(p)
+		b.WriteByte('\n')
+	}
+
+	return b.Bytes()
+}
+
+// LookupChecksum returns the hex-encoded digest for the file with the given name from the contents of a checksums file.
+// The contents can either be in the format of "sha256sum", e.g. a "SHA256SUMS" file of a release page, in which case the entry with the same file name is used, or hold only a digest, e.g. a ".sha256" file next to the file.
+func LookupChecksum(contents []byte, fileName string) (digest string, err error) {
+	entries, err := ParseChecksums(contents)
+	if err != nil {
+		return "", err
+	}
+
+	fileName = filepath.Base(fileName)
+	for _, entry := range entries {
+		if entry.Path == "" && len(entries) == 1 {
+			return entry.Digest, nil
+		} else if path.Base(filepath.ToSlash(entry.Path)) == fileName {
+			return entry.Digest, nil
+		}
+	}
+
+	return "", fmt.Errorf("%w for %q", ErrChecksumNotFound, fileName)
+}
+
+// WriteSHA256Sums writes a "SHA256SUMS" file in the format of "sha256sum" into the given directory and all its subdirectories, which holds the SHA-256 checksums of the files of its directory.
+func WriteSHA256Sums(directoryPath string) (err error) {
+	return filepath.WalkDir(directoryPath, func(directory string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() {
+			return nil
+		}
+
+		files, err := os.ReadDir(directory)
+		if err != nil {
+			return err
+		}
+		var entries []ChecksumsEntry
+		for _, file := range files {
+			if file.IsDir() || file.Name() == SHA256SumsFileName || strings.HasSuffix(file.Name(), ".sha256") {
+				continue
+			}
+
+			digest, err := checksumForFile(filepath.Join(directory, file.Name()), ChecksumAlgorithmSHA256)
+			if err != nil {
+				return err
+			}
+			entries = append(entries, ChecksumsEntry{
+				Digest: hex.EncodeToString(digest),
+				Path:   file.Name(),
+				Binary: true,
+			})
+		}
+		if len(entries) == 0 {
+			return nil
+		}
+		sort.Slice(entries, func(i, j int) bool {
+			return entries[i].Path < entries[j].Path
+		})
+
+		return os.WriteFile(filepath.Join(directory, SHA256SumsFileName), FormatChecksums(entries), 0644)
+	})
+}
+
+// VerifySHA256Sums verifies the files listed in the given checksums file in the format of "sha256sum" and returns the paths of files that do not match or that cannot be read.
+// Relative paths are resolved relative to the directory of the checksums file.
+func VerifySHA256Sums(checksumsFile string) (failed []string, err error) {
+	contents, err := os.ReadFile(checksumsFile)
+	if err != nil {
+		return nil, err
+	}
+	entries, err := ParseChecksums(contents)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, entry := range entries {
+		if entry.Path == "" {
+			return nil, fmt.Errorf("checksum without a file path in %q", checksumsFile)
+		}
+
+		filePath := filepath.FromSlash(entry.Path)
+		if !filepath.IsAbs(filePath) {
+			filePath = filepath.Join(filepath.Dir(checksumsFile), filePath)
+		}
+		valid, err := verifyFileChecksum(filePath, ChecksumAlgorithmSHA256, entry.Digest)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return nil, err
+		} else if !valid {
+			failed = append(failed, entry.Path)
+		}
+	}
+
+	return failed, nil
+}
+
+// VerifyFileSHA256 verifies the SHA-256 checksum of the given file with the given checksums file, which is either in the format of "sha256sum" or only holds the digest.
+func VerifyFileSHA256(filePath string, checksumsFile string) (valid bool, err error) {
+	contents, err := os.ReadFile(checksumsFile)
+	if err != nil {
+		return false, err
+	}
+	digest, err := LookupChecksum(contents, filePath)
+	if err != nil {
+		return false, err
+	}
+
+	return verifyFileChecksum(filePath, ChecksumAlgorithmSHA256, digest)
+}
+
+// verifyFileChecksum returns if the digest of the given file matches the given hex-encoded digest.
+func verifyFileChecksum(filePath string, algorithm ChecksumAlgorithm, expectedDigest string) (valid bool, err error) {
+	digest, err := checksumForFile(filePath, algorithm)
+	if err != nil {
+		return false, err
+	}
+
+	return strings.EqualFold(hex.EncodeToString(digest), expectedDigest), nil
+}
+
+// checksumForFile computes the digest of the contents of the given file.
+func checksumForFile(filePath string, algorithm ChecksumAlgorithm) (digest []byte, err error) {
+	hash, err := algorithm.New()
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if e := file.Close(); e != nil {
+			err = errors.Join(err, e)
+		}
+	}()
+
+	if _, err := io.Copy(hash, file); err != nil {
+		return nil, err
+	}
+
+	return hash.Sum(nil), nil
+}
@@ -0,0 +1,102 @@
+package osutil
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseChecksums(t *testing.T) {
+	type testCase struct {
+		Name string
+
+		Contents string
+
+		ExpectedEntries []ChecksumsEntry
+		ExpectedError   bool
+	}
+
+	validate := func(t *testing.T, tc *testCase) {
+		t.Run(tc.Name, func(t *testing.T) {
+			actualEntries, actualError := ParseChecksums([]byte(tc.Contents))
+			if tc.ExpectedError {
+				assert.Error(t, actualError)
+			} else {
+				assert.NoError(t, actualError)
+			}
+
+			assert.Equal(t, tc.ExpectedEntries, actualEntries)
+		})
+	}
+
+	validate(t, &testCase{
+		Name: "Digest only",
+
+		Contents: "ABCDEF",
+
+		ExpectedEntries: []ChecksumsEntry{
+			{Digest: "abcdef"},
+		},
+	})
+	validate(t, &testCase{
+		Name: "Text and binary mode",
+
+		Contents: "abcdef  some file\n012345 *some/other file\n\n",
+
+		ExpectedEntries: []ChecksumsEntry{
+			{Digest: "abcdef", Path: "some file"},
+			{Digest: "012345", Path: "some/other file", Binary: true},
+		},
+	})
+	validate(t, &testCase{
+		Name: "Escaped path",
+
+		Contents: "\\abcdef  some\\\\file\\nname\n",
+
+		ExpectedEntries: []ChecksumsEntry{
+			{Digest: "abcdef", Path: "some\\file\nname"},
+		},
+	})
+	validate(t, &testCase{
+		Name: "Invalid digest",
+
+		Contents: "xyz  some file\n",
+
+		ExpectedError: true,
+	})
+}
+
+func TestVerifySHA256Sums(t *testing.T) {
+	temporaryPath := t.TempDir()
+	checkTearError(t, os.WriteFile(filepath.Join(temporaryPath, "a"), []byte("a"), 0600))
+	checkTearError(t, WriteFile(filepath.Join(temporaryPath, "directory", "b"), []byte("b")))
+
+	require.NoError(t, WriteSHA256Sums(temporaryPath))
+	contents, err := os.ReadFile(filepath.Join(temporaryPath, SHA256SumsFileName))
+	require.NoError(t, err)
+	assert.Equal(t, "ca978112ca1bbdcafac231b39a23dc4da786eff8147c4e72b9807785afee48bb *a\n", string(contents))
+
+	failed, err := VerifySHA256Sums(filepath.Join(temporaryPath, "directory", SHA256SumsFileName))
+	require.NoError(t, err)
+	assert.Empty(t, failed)
+
+	valid, err := VerifyFileSHA256(filepath.Join(temporaryPath, "a"), filepath.Join(temporaryPath, SHA256SumsFileName))
+	require.NoError(t, err)
+	assert.True(t, valid)
+
+	require.NoError(t, ChecksumsSHA256ForFiles(filepath.Join(temporaryPath, "directory")))
+	valid, err = VerifyFileSHA256(filepath.Join(temporaryPath, "directory", "b"), filepath.Join(temporaryPath, "directory", "b.sha256"))
+	require.NoError(t, err)
+	assert.True(t, valid)
+
+	checkTearError(t, os.WriteFile(filepath.Join(temporaryPath, "a"), []byte("changed"), 0600))
+	failed, err = VerifySHA256Sums(filepath.Join(temporaryPath, SHA256SumsFileName))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a"}, failed)
+
+	_, err = VerifyFileSHA256(filepath.Join(temporaryPath, "unknown"), filepath.Join(temporaryPath, SHA256SumsFileName))
+	assert.ErrorIs(t, err, ErrChecksumNotFound)
+}
@@ -1,8 +1,10 @@
 package osutil
 
 import (
+	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -47,3 +49,196 @@ func TestChecksumForPath(t *testing.T) {
 	assert.NotEqual(t, digestForEmptyDirectory, digestWithFile)
 	assert.Equal(t, digestWithFile, digestWithSymlink)
 }
+
+func TestChecksumForPathWithOptions(t *testing.T) {
+	temporaryPath := t.TempDir()
+	checkTearError(t, os.WriteFile(filepath.Join(temporaryPath, "some file"), []byte("some content"), 0600))
+	checksumFile := filepath.Join(t.TempDir(), "checksum")
+
+	for _, algorithm := range []ChecksumAlgorithm{
+		ChecksumAlgorithmMD5,
+		ChecksumAlgorithmSHA256,
+		ChecksumAlgorithmSHA512,
+		ChecksumAlgorithmBLAKE2b256,
+		ChecksumAlgorithmBLAKE2b512,
+	} {
+		t.Run(string(algorithm), func(t *testing.T) {
+			assert.NoError(t, WriteChecksumForPathWithOptions(temporaryPath, checksumFile, ChecksumOptions{
+				Algorithm: algorithm,
+			}))
+			contents, err := os.ReadFile(checksumFile)
+			assert.NoError(t, err)
+			assert.True(t, strings.HasPrefix(string(contents), string(algorithm)+":"))
+
+			valid, err := ValidateChecksumForPath(temporaryPath, checksumFile)
+			assert.NoError(t, err)
+			assert.True(t, valid)
+		})
+	}
+
+	t.Run("Legacy MD5 checksum file", func(t *testing.T) {
+		digest, err := checksumForPathConcatenated(temporaryPath, ChecksumAlgorithmMD5)
+		assert.NoError(t, err)
+		checkTearError(t, os.WriteFile(checksumFile, []byte(fmt.Sprintf("%x\n", digest)), 0644))
+
+		valid, err := ValidateChecksumForPath(temporaryPath, checksumFile)
+		assert.NoError(t, err)
+		assert.True(t, valid)
+
+		checkTearError(t, os.WriteFile(filepath.Join(temporaryPath, "some file"), []byte("changed content"), 0600))
+		valid, err = ValidateChecksumForPath(temporaryPath, checksumFile)
+		assert.NoError(t, err)
+		assert.False(t, valid)
+	})
+
+	t.Run("Unknown algorithm", func(t *testing.T) {
+		checkTearError(t, os.WriteFile(checksumFile, []byte("crc32:abcd\n"), 0644))
+
+		_, err := ValidateChecksumForPath(temporaryPath, checksumFile)
+		assert.ErrorIs(t, err, ErrUnknownChecksumAlgorithm)
+	})
+}
+
+func TestChecksumForPathWithOptionsIsDeterministic(t *testing.T) {
+	temporaryPath := t.TempDir()
+	for i := 0; i < 100; i++ {
+		checkTearError(t, WriteFile(filepath.Join(temporaryPath, fmt.Sprintf("directory %d", i%7), fmt.Sprintf("file %d", i)), []byte(strings.Repeat(fmt.Sprintf("%d", i), i*100))))
+	}
+
+	expectedDigest, err := ChecksumForPathWithOptions(temporaryPath, ChecksumOptions{
+		Workers: 1,
+	})
+	assert.NoError(t, err)
+	for _, workers := range []int{2, 16} {
+		digest, err := ChecksumForPathWithOptions(temporaryPath, ChecksumOptions{
+			Workers: workers,
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, expectedDigest, digest)
+	}
+	// The default checksum is hashed in parallel as well.
+	digest, err := ChecksumForPath(temporaryPath)
+	assert.NoError(t, err)
+	assert.Equal(t, expectedDigest, digest)
+
+	checkTearError(t, os.WriteFile(filepath.Join(temporaryPath, "directory 0", "file 0"), []byte("changed"), 0600))
+	digest, err = ChecksumForPathWithOptions(temporaryPath, ChecksumOptions{})
+	assert.NoError(t, err)
+	assert.NotEqual(t, expectedDigest, digest)
+}
+
+func TestChecksumForPathWithOptionsMetadata(t *testing.T) {
+	if IsWindows() {
+		t.SkipNow() // TODO Implement symlink handling under Windows or make this test case compatible with Windows. https://$INTERNAL/symflower/symflower/-/issues/3637
+	}
+
+	type testCase struct {
+		Name string
+
+		Options ChecksumOptions
+		Change  func(t *testing.T, path string)
+
+		ExpectedChange bool
+	}
+
+	validate := func(t *testing.T, tc *testCase) {
+		t.Run(tc.Name, func(t *testing.T) {
+			path := t.TempDir()
+			checkTearError(t, os.WriteFile(filepath.Join(path, "some file"), []byte("some content"), 0600))
+			checkTearError(t, os.WriteFile(filepath.Join(path, "other file"), []byte("some content"), 0600))
+			checkTearError(t, os.Symlink("some file", filepath.Join(path, "symlink")))
+
+			digestBefore, err := ChecksumForPathWithOptions(path, tc.Options)
+			assert.NoError(t, err)
+
+			tc.Change(t, path)
+
+			digestAfter, err := ChecksumForPathWithOptions(path, tc.Options)
+			assert.NoError(t, err)
+
+			if tc.ExpectedChange {
+				assert.NotEqual(t, digestBefore, digestAfter)
+			} else {
+				assert.Equal(t, digestBefore, digestAfter)
+			}
+		})
+	}
+
+	retargetSymlink := func(t *testing.T, path string) {
+		checkTearError(t, os.Remove(filepath.Join(path, "symlink")))
+		checkTearError(t, os.Symlink("other file", filepath.Join(path, "symlink")))
+	}
+	validate(t, &testCase{
+		Name: "Retargeted symlink with the same contents",
+
+		Change: retargetSymlink,
+
+		ExpectedChange: false,
+	})
+	validate(t, &testCase{
+		Name: "Retargeted symlink hashed by target",
+
+		Options: ChecksumOptions{
+			SymlinksAsTarget: true,
+		},
+		Change: retargetSymlink,
+
+		ExpectedChange: true,
+	})
+
+	makeExecutable := func(t *testing.T, path string) {
+		checkTearError(t, os.Chmod(filepath.Join(path, "some file"), 0700))
+	}
+	validate(t, &testCase{
+		Name: "Executable bit",
+
+		Change: makeExecutable,
+
+		ExpectedChange: false,
+	})
+	validate(t, &testCase{
+		Name: "Executable bit with mode",
+
+		Options: ChecksumOptions{
+			IncludeMode: true,
+		},
+		Change: makeExecutable,
+
+		ExpectedChange: true,
+	})
+
+	addEmptyDirectory := func(t *testing.T, path string) {
+		checkTearError(t, os.MkdirAll(filepath.Join(path, "empty", "directory"), 0700))
+	}
+	validate(t, &testCase{
+		Name: "Empty directory",
+
+		Change: addEmptyDirectory,
+
+		ExpectedChange: false,
+	})
+	validate(t, &testCase{
+		Name: "Empty directory included",
+
+		Options: ChecksumOptions{
+			IncludeEmptyDirectories: true,
+		},
+		Change: addEmptyDirectory,
+
+		ExpectedChange: true,
+	})
+
+	validate(t, &testCase{
+		Name: "Excluded files",
+
+		Options: ChecksumOptions{
+			Excludes: []string{".git", "checksum.*"},
+		},
+		Change: func(t *testing.T, path string) {
+			checkTearError(t, WriteFile(filepath.Join(path, ".git", "HEAD"), []byte("ref")))
+			checkTearError(t, WriteChecksumForPath(path, filepath.Join(path, "checksum.txt")))
+		},
+
+		ExpectedChange: false,
+	})
+}
@@ -0,0 +1,141 @@
+package osutil
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"os/exec"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ErrMemoryLimitExceeded indicates that a command has been killed because it exceeded its memory limit.
+var ErrMemoryLimitExceeded = errors.New("memory limit exceeded")
+
+// CommandOptions holds options for executing a command.
+type CommandOptions struct {
+	// Directory holds the working directory of the command. If empty, the current working directory is used.
+	Directory string
+	// Environment holds the environment variables of the command, e.g. a modified result of "EnvironMap". If nil, the environment of the current process is used.
+	Environment map[string]string
+	// Stdin holds the input of the command.
+	Stdin io.Reader
+
+	// Timeout holds the maximum duration of the command after which the command and all its descendant processes are killed.
+	// Zero means no timeout.
+	Timeout time.Duration
+	// Limits holds limits that are enforced on the command and all its descendant processes. If the limits are exceeded and the limits do not define a handler, the command and all its descendant processes are killed and "ErrMemoryLimitExceeded" is returned together with the result.
+	// Limits are currently only enforced on Linux.
+	Limits *ProcessTreeLimits
+}
+
+// CommandResult holds the result of an executed command.
+type CommandResult struct {
+	// Stdout holds the output written to STDOUT.
+	Stdout []byte
+	// Stderr holds the output written to STDERR.
+	Stderr []byte
+	// Output holds the output written to STDOUT and STDERR in the order it has been received.
+	Output []byte
+
+	// ExitCode holds the exit code of the command, or -1 if the command has been terminated by a signal.
+	ExitCode int
+	// Signal holds the signal that terminated the command, or nil if the command exited regularly.
+	Signal os.Signal
+	// Duration holds the time the command has been running.
+	Duration time.Duration
+}
+
+// CommandExecute executes the given command and returns its result.
+// An exit code that is not zero is not an error. If the context is done or the timeout is reached, the command and all its descendant processes are killed and the context error is returned together with the result.
+func CommandExecute(ctx context.Context, options CommandOptions, name string, arguments ...string) (result *CommandResult, err error) {
+	if options.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, options.Timeout)
+		defer cancel()
+	}
+
+	cmd := exec.CommandContext(ctx, name, arguments...)
+	cmd.Dir = options.Directory
+	if options.Environment != nil {
+		cmd.Env = environMapToList(options.Environment)
+	}
+	cmd.Stdin = options.Stdin
+
+	var stdout, stderr, output bytes.Buffer
+	var outputLock sync.Mutex
+	cmd.Stdout = &synchronizedWriter{lock: &outputLock, writer: io.MultiWriter(&stdout, &output)}
+	cmd.Stderr = &synchronizedWriter{lock: &outputLock, writer: io.MultiWriter(&stderr, &output)}
+
+	commandSetProcessGroup(cmd)
+	cmd.Cancel = func() error {
+		return commandKillProcessGroup(cmd.Process)
+	}
+
+	start := time.Now()
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+
+	var memoryLimitExceeded atomic.Bool
+	if options.Limits != nil {
+		stopLimits := enforceProcessTreeLimitsOfProcess(cmd.Process.Pid, *options.Limits, func() {
+			memoryLimitExceeded.Store(true)
+			_ = commandKillProcessGroup(cmd.Process)
+		})
+		defer stopLimits()
+	}
+
+	err = cmd.Wait()
+	result = &CommandResult{
+		Stdout: stdout.Bytes(),
+		Stderr: stderr.Bytes(),
+		Output: output.Bytes(),
+
+		ExitCode: cmd.ProcessState.ExitCode(),
+		Signal:   commandSignal(cmd.ProcessState),
+		Duration: time.Since(start),
+	}
+
+	if ctx.Err() != nil {
+		return result, ctx.Err()
+	}
+	if memoryLimitExceeded.Load() {
+		return result, ErrMemoryLimitExceeded
+	}
+	var exitError *exec.ExitError
+	if err != nil && !errors.As(err, &exitError) {
+		return result, err
+	}
+
+	return result, nil
+}
+
+// environMapToList returns the given environment variables as sorted list of "key=value" pairs.
+func environMapToList(environMap map[string]string) (environ []string) {
+	environ = make([]string, 0, len(environMap))
+	for k, v := range environMap {
+		environ = append(environ, k+"="+v)
+	}
+	sort.Strings(environ)
+
+	return environ
+}
+
+// synchronizedWriter serializes writing to a writer with a lock that can be shared with other writers.
+type synchronizedWriter struct {
+	lock   *sync.Mutex
+	writer io.Writer
+}
+
+// Write writes the given data while holding the lock.
+func (w *synchronizedWriter) Write(data []byte) (n int, err error) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	return w.writer.Write(data)
+}
@@ -0,0 +1,36 @@
+//go:build !windows
+
+package osutil
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"syscall"
+)
+
+// commandSetProcessGroup lets the command run in its own process group, so it can be killed together with its descendant processes.
+func commandSetProcessGroup(cmd *exec.Cmd) {
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.Setpgid = true
+}
+
+// commandKillProcessGroup kills the process group of the given process.
+func commandKillProcessGroup(process *os.Process) error {
+	if err := syscall.Kill(-process.Pid, syscall.SIGKILL); err != nil && !errors.Is(err, syscall.ESRCH) {
+		return err
+	}
+
+	return nil
+}
+
+// commandSignal returns the signal that terminated the process, or nil if the process exited regularly.
+func commandSignal(state *os.ProcessState) os.Signal {
+	if status, ok := state.Sys().(syscall.WaitStatus); ok && status.Signaled() {
+		return status.Signal()
+	}
+
+	return nil
+}
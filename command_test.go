@@ -0,0 +1,114 @@
+package osutil
+
+import (
+	"context"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCommandExecute(t *testing.T) {
+	if IsWindows() {
+		t.SkipNow() // TODO Make this test case compatible with Windows when the command execution is used there.
+	}
+
+	type testCase struct {
+		Name string
+
+		Options   CommandOptions
+		Arguments []string
+
+		ExpectedResult *CommandResult
+		ExpectedError  error
+	}
+
+	validate := func(t *testing.T, tc *testCase) {
+		t.Run(tc.Name, func(t *testing.T) {
+			actualResult, actualError := CommandExecute(context.Background(), tc.Options, tc.Arguments[0], tc.Arguments[1:]...)
+			if tc.ExpectedError != nil {
+				assert.ErrorIs(t, actualError, tc.ExpectedError)
+			} else {
+				assert.NoError(t, actualError)
+			}
+
+			require.NotNil(t, actualResult)
+			assert.Positive(t, actualResult.Duration)
+			actualResult.Duration = 0
+			assert.Equal(t, tc.ExpectedResult, actualResult)
+		})
+	}
+
+	validate(t, &testCase{
+		Name: "Separate streams",
+
+		Arguments: []string{"sh", "-c", "echo a; sleep 0.1; echo b >&2; sleep 0.1; echo c"},
+
+		ExpectedResult: &CommandResult{
+			Stdout: []byte("a\nc\n"),
+			Stderr: []byte("b\n"),
+			Output: []byte("a\nb\nc\n"),
+		},
+	})
+	validate(t, &testCase{
+		Name: "Exit code",
+
+		Arguments: []string{"sh", "-c", "exit 3"},
+
+		ExpectedResult: &CommandResult{
+			ExitCode: 3,
+		},
+	})
+	validate(t, &testCase{
+		Name: "Directory and environment",
+
+		Options: CommandOptions{
+			Directory: "/",
+			Environment: map[string]string{
+				"SOME_VARIABLE": "some value",
+			},
+		},
+		Arguments: []string{"sh", "-c", "echo \"$PWD $SOME_VARIABLE\""},
+
+		ExpectedResult: &CommandResult{
+			Stdout: []byte("/ some value\n"),
+			Output: []byte("/ some value\n"),
+		},
+	})
+	validate(t, &testCase{
+		Name: "Timeout kills the process group",
+
+		Options: CommandOptions{
+			Timeout: 100 * time.Millisecond,
+		},
+		Arguments: []string{"sh", "-c", "sleep 10 & sleep 10; wait"},
+
+		ExpectedResult: &CommandResult{
+			ExitCode: -1,
+			Signal:   syscall.SIGKILL,
+		},
+		ExpectedError: context.DeadlineExceeded,
+	})
+	if IsLinux() { // TODO Remove this condition when limits are enforced on other operating systems as well.
+		validate(t, &testCase{
+			Name: "Memory limit of descendant processes kills the process group",
+
+			Options: CommandOptions{
+				Limits: &ProcessTreeLimits{
+					MaxMemoryInMiB:   50,
+					WatchdogInterval: 50 * time.Millisecond,
+				},
+			},
+			// The data is held in memory by "tail", which is a descendant process, until "sleep" reads its output.
+			Arguments: []string{"sh", "-c", "head -c 200M /dev/zero | tail -c 200M | sleep 10"},
+
+			ExpectedResult: &CommandResult{
+				ExitCode: -1,
+				Signal:   syscall.SIGKILL,
+			},
+			ExpectedError: ErrMemoryLimitExceeded,
+		})
+	}
+}
@@ -0,0 +1,21 @@
+package osutil
+
+import (
+	"os"
+	"os/exec"
+)
+
+// commandSetProcessGroup lets the command run in its own process group, so it can be killed together with its descendant processes.
+func commandSetProcessGroup(cmd *exec.Cmd) {
+	// WORKAROUND Windows has no process groups that can be signaled, so only the process itself is killed. Implement job objects when it is actually needed.
+}
+
+// commandKillProcessGroup kills the process group of the given process.
+func commandKillProcessGroup(process *os.Process) error {
+	return process.Kill()
+}
+
+// commandSignal returns the signal that terminated the process, or nil if the process exited regularly.
+func commandSignal(state *os.ProcessState) os.Signal {
+	return nil
+}
@@ -5,43 +5,153 @@ import (
 	"io"
 	"os"
 	"os/signal"
+	"sync"
 	"syscall"
+	"time"
 )
 
-// ContextWithInterrupt returns a context which can be interrupted by signals "SIGTERM" and "SIGQUIT".
+// InterruptOptions holds options for a context which can be interrupted by signals.
+type InterruptOptions struct {
+	// Signals holds the signals that interrupt the context. Defaults to "SIGINT", "SIGTERM" and "SIGQUIT".
+	Signals []os.Signal
+	// LogWriter holds the writer for the messages. Messages are discarded if it is nil.
+	LogWriter io.Writer
+
+	// MessageReceived holds the message that is written whenever a signal is received.
+	MessageReceived string
+	// MessageCanceling holds the message that is written when the context is canceled.
+	MessageCanceling string
+	// MessageExiting holds the message that is written before the program is terminated.
+	MessageExiting string
+
+	// GracePeriod holds the duration after the first signal after which the program is terminated even if no further signal is received. There is no forced termination if it is zero.
+	GracePeriod time.Duration
+	// Shutdown holds an optional registry whose hooks are run when the first signal is received. The hooks are canceled after the grace period and their errors are written to the log writer before the program is terminated. The program should run the registry on a normal exit as well, which also returns the aggregated errors of the hooks.
+	Shutdown *ShutdownRegistry
+
+	// Exit is called instead of "os.Exit(1)" to terminate the program on a further signal or after the grace period.
+	Exit func()
+}
+
+// interruptShutdownLogTimeout holds the duration that is waited after the grace period for the shutdown hooks to return and their errors to be logged.
+const interruptShutdownLogTimeout = time.Second
+
+// ContextWithInterrupt returns a context which can be interrupted by signals "SIGINT", "SIGTERM" and "SIGQUIT".
 // If the signal is sent once, then the returned context is cancelled. If multiple signals are sent, then the program terminates via "os.Exit(1)".
 func ContextWithInterrupt(ctx context.Context, logWriter io.Writer) (contextWithInterrupt context.Context, cancelContext context.CancelFunc) {
-	contextWithInterrupt, cancelContext = context.WithCancel(ctx)
+	return ContextWithInterruptWithOptions(ctx, InterruptOptions{
+		LogWriter: logWriter,
+
+		MessageReceived:  "Received termination signal",
+		MessageCanceling: "Canceling analysis and writing results",
+		MessageExiting:   "Exiting immediately",
+	})
+}
+
+// ContextWithInterruptWithOptions returns a context which can be interrupted by signals with the given options.
+// If a signal is sent once, then the returned context is cancelled. If another signal is sent or the grace period is over, then the program terminates.
+// The signals are handled until the parent context is done or the returned cancel function is called.
+func ContextWithInterruptWithOptions(ctx context.Context, options InterruptOptions) (contextWithInterrupt context.Context, cancelContext context.CancelFunc) {
+	contextWithInterrupt, cancel := context.WithCancel(ctx)
+
+	signals := options.Signals
+	if len(signals) == 0 {
+		signals = []os.Signal{os.Interrupt, syscall.SIGTERM, syscall.SIGQUIT}
+	}
+	exit := options.Exit
+	if exit == nil {
+		exit = func() {
+			//revive:disable:deep-exit
+			os.Exit(1)
+			//revive:enable:deep-exit
+		}
+	}
+	var logLock sync.Mutex
+	log := func(message string) {
+		if options.LogWriter == nil || message == "" {
+			return
+		}
+
+		logLock.Lock()
+		defer logLock.Unlock()
+
+		if _, err := io.WriteString(options.LogWriter, message); err != nil {
+			panic(err)
+		}
+	}
 
 	c := make(chan os.Signal, 10)
-	signal.Notify(c, os.Interrupt, syscall.SIGTERM, syscall.SIGQUIT)
+	signal.Notify(c, signals...)
+
+	stop := make(chan struct{})
+	var stopOnce sync.Once
+	cancelContext = func() {
+		stopOnce.Do(func() {
+			close(stop)
+		})
+		cancel()
+	}
+
 	go func() {
+		defer signal.Stop(c)
+
+		var gracePeriod <-chan time.Time
+		var shutdownDone chan struct{}
 		count := 0
 		for {
-			switch <-c {
-			case os.Interrupt, os.Kill:
-				_, err := io.WriteString(logWriter, "Received termination signal")
-				if err != nil {
-					panic(err)
+			select {
+			case <-stop:
+				return
+			case <-ctx.Done():
+				return
+			case <-gracePeriod:
+				if shutdownDone != nil {
+					// The hooks are canceled at the same time, so give them a moment to return and log their errors.
+					select {
+					case <-shutdownDone:
+					case <-time.After(interruptShutdownLogTimeout):
+					}
 				}
+
+				log(options.MessageExiting)
+				exit()
+
+				return
+			case <-c:
+				log(options.MessageReceived)
 				count++
 
 				if count == 1 {
-					_, err := io.WriteString(logWriter, "Canceling analysis and writing results")
-					if err != nil {
-						panic(err)
-					}
+					log(options.MessageCanceling)
+					cancel()
 
-					cancelContext()
-				} else {
-					_, err := io.WriteString(logWriter, "Exiting immediately")
-					if err != nil {
-						panic(err)
+					if options.Shutdown != nil {
+						shutdownDone = make(chan struct{})
+						go func() {
+							defer close(shutdownDone)
+
+							ctx := context.Background()
+							if options.GracePeriod > 0 {
+								var cancel context.CancelFunc
+								ctx, cancel = context.WithTimeout(ctx, options.GracePeriod)
+								defer cancel()
+							}
+
+							if err := options.Shutdown.Run(ctx); err != nil {
+								log(err.Error() + "\n")
+							}
+						}()
+					}
+					if options.GracePeriod > 0 {
+						timer := time.NewTimer(options.GracePeriod)
+						defer timer.Stop()
+						gracePeriod = timer.C
 					}
+				} else {
+					log(options.MessageExiting)
+					exit()
 
-					//revive:disable:deep-exit
-					os.Exit(1)
-					//revive:enable:deep-exit
+					return
 				}
 			}
 		}
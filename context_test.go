@@ -0,0 +1,87 @@
+package osutil
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestContextWithInterruptWithOptions(t *testing.T) {
+	if IsWindows() {
+		t.Skip("Signals cannot be sent to the own process on Windows")
+	}
+
+	process, err := os.FindProcess(os.Getpid())
+	require.NoError(t, err)
+
+	t.Run("Second signal exits", func(t *testing.T) {
+		var log bytes.Buffer
+		exited := make(chan struct{})
+		ctx, cancel := ContextWithInterruptWithOptions(context.Background(), InterruptOptions{
+			Signals:   []os.Signal{syscall.SIGTERM},
+			LogWriter: &log,
+
+			MessageReceived:  "received\n",
+			MessageCanceling: "canceling\n",
+			MessageExiting:   "exiting\n",
+
+			Exit: func() {
+				close(exited)
+			},
+		})
+		defer cancel()
+
+		require.NoError(t, process.Signal(syscall.SIGTERM))
+		select {
+		case <-ctx.Done():
+		case <-time.After(5 * time.Second):
+			require.Fail(t, "context has not been canceled")
+		}
+
+		require.NoError(t, process.Signal(syscall.SIGTERM))
+		select {
+		case <-exited:
+		case <-time.After(5 * time.Second):
+			require.Fail(t, "program has not been exited")
+		}
+
+		assert.Equal(t, "received\ncanceling\nreceived\nexiting\n", log.String())
+	})
+
+	t.Run("Grace period exits", func(t *testing.T) {
+		exited := make(chan struct{})
+		ctx, cancel := ContextWithInterruptWithOptions(context.Background(), InterruptOptions{
+			Signals:     []os.Signal{syscall.SIGTERM},
+			GracePeriod: 10 * time.Millisecond,
+			Exit: func() {
+				close(exited)
+			},
+		})
+		defer cancel()
+
+		require.NoError(t, process.Signal(syscall.SIGTERM))
+		<-ctx.Done()
+		select {
+		case <-exited:
+		case <-time.After(5 * time.Second):
+			require.Fail(t, "program has not been exited")
+		}
+	})
+
+	t.Run("Cancel stops handling", func(t *testing.T) {
+		ctx, cancel := ContextWithInterruptWithOptions(context.Background(), InterruptOptions{
+			Signals: []os.Signal{syscall.SIGTERM},
+			Exit: func() {
+				assert.Fail(t, "program must not be exited")
+			},
+		})
+		cancel()
+		<-ctx.Done()
+	})
+}
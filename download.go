@@ -1,115 +1,710 @@
 package osutil
 
 import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"hash"
 	"io"
 	"net"
 	"net/http"
 	"net/http/cookiejar"
+	neturl "net/url"
 	"os"
+	pathpkg "path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
 	"time"
+
+	"github.com/avast/retry-go"
 )
 
-// HTTPClient defines an HTTP client with sane default settings.
+// HTTPClient defines an HTTP client with sane default settings. It can be replaced with a client of "NewHTTPClient" to change the default client of all downloads.
 var HTTPClient *http.Client = func() *http.Client {
-	c := &http.Client{
+	c, err := NewHTTPClient(HTTPClientOptions{})
+	if err != nil {
+		panic(err)
+	}
+
+	return c
+}()
+
+// HTTPClientOptions holds options for an HTTP client.
+type HTTPClientOptions struct {
+	// Proxy returns the proxy for a request. Defaults to the proxy of the environment variables "HTTP_PROXY", "HTTPS_PROXY" and "NO_PROXY".
+	Proxy func(request *http.Request) (*neturl.URL, error)
+
+	// RootCAs holds paths of PEM files, or directories with ".pem", ".crt" and ".cer" files, with certificates of certificate authorities that are trusted in addition to the ones of the system.
+	RootCAs []string
+	// ClientCertificateFile holds the path of a PEM file with a client certificate for mutual TLS authentication.
+	ClientCertificateFile string
+	// ClientKeyFile holds the path of a PEM file with the private key of the client certificate.
+	ClientKeyFile string
+}
+
+// NewHTTPClient returns an HTTP client with sane default settings and the given options.
+func NewHTTPClient(options HTTPClientOptions) (client *http.Client, err error) {
+	proxy := options.Proxy
+	if proxy == nil {
+		proxy = http.ProxyFromEnvironment
+	}
+
+	tlsConfig, err := newHTTPClientTLSConfig(options)
+	if err != nil {
+		return nil, err
+	}
+
+	client = &http.Client{
 		// The timeout defaults of the default client are terrible. See https://blog.cloudflare.com/the-complete-guide-to-golang-net-http-timeouts/ for details.
 		Transport: &http.Transport{
-			Dial: (&net.Dialer{
+			Proxy: proxy,
+			DialContext: (&net.Dialer{
 				Timeout:   30 * time.Second,
 				KeepAlive: 30 * time.Second,
-			}).Dial,
+			}).DialContext,
+			TLSClientConfig:       tlsConfig,
 			TLSHandshakeTimeout:   10 * time.Second,
 			ResponseHeaderTimeout: 10 * time.Second,
 			ExpectContinueTimeout: 1 * time.Second,
 		},
 		Timeout: 0, // This timeout includes the whole process of downloading a file. Hence, big files always run into a timeout so we are setting the timeout granularly.
 	}
-	c.Jar, _ = cookiejar.New(nil)
+	client.Jar, _ = cookiejar.New(nil)
 
-	return c
-}()
+	return client, nil
+}
+
+// newHTTPClientTLSConfig returns the TLS configuration for the given options, which is nil if the defaults should be used.
+func newHTTPClientTLSConfig(options HTTPClientOptions) (tlsConfig *tls.Config, err error) {
+	if len(options.RootCAs) == 0 && options.ClientCertificateFile == "" && options.ClientKeyFile == "" {
+		return nil, nil
+	}
+
+	tlsConfig = &tls.Config{
+		MinVersion: tls.VersionTLS12,
+	}
+
+	if len(options.RootCAs) > 0 {
+		tlsConfig.RootCAs, err = x509.SystemCertPool()
+		if err != nil || tlsConfig.RootCAs == nil {
+			tlsConfig.RootCAs = x509.NewCertPool()
+		}
+
+		for _, rootCA := range options.RootCAs {
+			if err := appendCertificates(tlsConfig.RootCAs, rootCA); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if options.ClientCertificateFile != "" || options.ClientKeyFile != "" {
+		certificate, err := tls.LoadX509KeyPair(options.ClientCertificateFile, options.ClientKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("cannot load client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{certificate}
+	}
+
+	return tlsConfig, nil
+}
+
+// appendCertificates appends the certificates of the given PEM file, or of the ".pem", ".crt" and ".cer" files of the given directory, to the pool.
+func appendCertificates(pool *x509.CertPool, path string) (err error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+
+	filePaths := []string{path}
+	if info.IsDir() {
+		filePaths = nil
+
+		entries, err := os.ReadDir(path)
+		if err != nil {
+			return err
+		}
+		for _, entry := range entries {
+			switch strings.ToLower(filepath.Ext(entry.Name())) {
+			case ".pem", ".crt", ".cer":
+				if !entry.IsDir() {
+					filePaths = append(filePaths, filepath.Join(path, entry.Name()))
+				}
+			}
+		}
+	}
+
+	for _, filePath := range filePaths {
+		data, err := os.ReadFile(filePath)
+		if err != nil {
+			return err
+		}
+		if !pool.AppendCertsFromPEM(data) {
+			return fmt.Errorf("no certificates found in %q", filePath)
+		}
+	}
+
+	return nil
+}
+
+// DownloadOptions holds options for downloading a file.
+type DownloadOptions struct {
+	// Client holds the HTTP client for the requests. Defaults to "HTTPClient".
+	Client *http.Client
+	// Headers holds additional headers for the requests, e.g. a "User-Agent".
+	Headers http.Header
+	// Authenticate is called to authenticate every request, e.g. with "BearerAuthentication" or "BasicAuthentication".
+	Authenticate func(request *http.Request) error
+
+	// ProgressWriter holds the writer a progress is printed to. No progress is printed if it is nil.
+	ProgressWriter io.Writer
+	// Segments holds the number of concurrent range requests a download is split into if the server supports range requests. Segmented downloads cannot be resumed by later calls, and their checksum is verified by reading the file again after the download instead of while downloading. Downloads are not split if it is less than 2.
+	Segments int
+	// KeepPartialFile keeps the ".part" file of a failed download, so a later download of the same URL to the same file path can resume it. By default, the ".part" file is removed on failure, so only retries within one download are resumed.
+	KeepPartialFile bool
+
+	// Attempts holds the number of attempts for downloads that fail with transient errors, e.g. connection resets, timeouts, status code 429 and server errors. Defaults to 5.
+	Attempts uint
+	// Delay holds the delay before the first retry, which is doubled for every further retry. Defaults to 1 second.
+	Delay time.Duration
+	// MaxDelay holds the maximum delay between retries. Delays that are requested by the server via a "Retry-After" header are not limited. Defaults to 30 seconds.
+	MaxDelay time.Duration
+
+	// ChecksumAlgorithm holds the algorithm of the expected checksum. Defaults to SHA-256.
+	ChecksumAlgorithm ChecksumAlgorithm
+	// Checksum holds the hex-encoded expected digest of the file, which is verified while the file is downloaded. The file is not verified if it is empty and there is no checksum URL.
+	Checksum string
+	// ChecksumURL holds the URL of a checksums file the expected digest is looked up from, if there is no expected digest, e.g. the URL of the file with a ".sha256" suffix. The checksums file is either in the format of "sha256sum" or only holds the digest.
+	ChecksumURL string
+}
+
+// ChecksumMismatchError holds an error for a file whose digest does not match the expected digest.
+type ChecksumMismatchError struct {
+	// Algorithm holds the algorithm of the digests.
+	Algorithm ChecksumAlgorithm
+	// Expected holds the hex-encoded expected digest.
+	Expected string
+	// Actual holds the hex-encoded actual digest.
+	Actual string
+}
+
+// Error returns the error message.
+func (e *ChecksumMismatchError) Error() string {
+	return fmt.Sprintf("%s checksum mismatch: expected %s but got %s", e.Algorithm, e.Expected, e.Actual)
+}
+
+// BearerAuthentication returns an authentication for downloads with the given bearer token.
+func BearerAuthentication(token string) func(request *http.Request) error {
+	return func(request *http.Request) error {
+		request.Header.Set("Authorization", "Bearer "+token)
+
+		return nil
+	}
+}
+
+// BasicAuthentication returns an authentication for downloads with the given username and password.
+func BasicAuthentication(username string, password string) func(request *http.Request) error {
+	return func(request *http.Request) error {
+		request.SetBasicAuth(username, password)
+
+		return nil
+	}
+}
 
 // DownloadFile downloads a file from the URL to the file path.
+// The file is downloaded into a ".part" file next to the file path first, which is resumed by retries if the download fails and the server supports range requests.
 func DownloadFile(url string, filePath string) (err error) {
-	resp, err := HTTPClient.Get(url)
+	return DownloadFileWithOptions(context.Background(), url, filePath, DownloadOptions{})
+}
+
+// DownloadFileWithProgress downloads a file from the URL to the file path while printing a progress to STDOUT.
+// The file is downloaded into a ".part" file next to the file path first, which is resumed by retries if the download fails and the server supports range requests.
+func DownloadFileWithProgress(url string, filePath string) (err error) {
+	return DownloadFileWithOptions(context.Background(), url, filePath, DownloadOptions{
+		ProgressWriter: os.Stdout,
+	})
+}
+
+// DownloadFileWithChecksum downloads a file from the URL to the file path and verifies the contents with the given hex-encoded digest while the file is downloaded.
+// If the digest does not match, the file is removed and a "*ChecksumMismatchError" is returned.
+func DownloadFileWithChecksum(url string, filePath string, algorithm ChecksumAlgorithm, digest string) (err error) {
+	return DownloadFileWithOptions(context.Background(), url, filePath, DownloadOptions{
+		ChecksumAlgorithm: algorithm,
+		Checksum:          digest,
+	})
+}
+
+// DownloadFileWithOptions downloads a file from the URL to the file path with the given options. The download is canceled when the given context is done.
+// The file is downloaded into a ".part" file next to the file path first, which is resumed by retries if the download fails and the server supports range requests. Resuming a failed download with a later call requires "KeepPartialFile".
+func DownloadFileWithOptions(ctx context.Context, url string, filePath string, options DownloadOptions) (err error) {
+	if err := options.prepare(ctx, url); err != nil {
+		return err
+	}
+
+	if size, validator, ok := downloadSegmentable(ctx, url, options); ok {
+		err = downloadFileSegmented(ctx, url, filePath, size, validator, options)
+	} else {
+		err = retry.Do(
+			func() error {
+				return downloadFile(ctx, url, filePath, options)
+			},
+			options.retryOptions(ctx)...,
+		)
+	}
 	if err != nil {
+		if !options.KeepPartialFile {
+			err = errors.Join(err, downloadRemovePartFiles(filePath+downloadPartFileSuffix, filePath+downloadPartStateFileSuffix))
+		}
+
 		return err
 	}
-	defer func() {
-		if e := resp.Body.Close(); e != nil {
-			err = errors.Join(err, e)
+
+	return nil
+}
+
+// prepare sets the defaults of the options and looks up the expected checksum for downloading the URL.
+func (o *DownloadOptions) prepare(ctx context.Context, url string) (err error) {
+	if o.Client == nil {
+		o.Client = HTTPClient
+	}
+	if o.ChecksumAlgorithm == "" {
+		o.ChecksumAlgorithm = ChecksumAlgorithmSHA256
+	}
+	if _, err := o.ChecksumAlgorithm.New(); err != nil {
+		return err
+	}
+
+	if o.Checksum == "" && o.ChecksumURL != "" {
+		var contents []byte
+		if err := retry.Do(
+			func() (err error) {
+				contents, err = downloadBytes(ctx, o.ChecksumURL, *o)
+
+				return err
+			},
+			o.retryOptions(ctx)...,
+		); err != nil {
+			return err
 		}
-	}()
 
-	out, err := os.Create(filePath)
+		if o.Checksum, err = LookupChecksum(contents, downloadFileName(url)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// retryOptions returns the options for retrying downloads, which stop retrying when the given context is done.
+func (o DownloadOptions) retryOptions(ctx context.Context) []retry.Option {
+	attempts := o.Attempts
+	if attempts == 0 {
+		attempts = 5
+	}
+	delay := o.Delay
+	if delay <= 0 {
+		delay = time.Second
+	}
+	maxDelay := o.MaxDelay
+	if maxDelay <= 0 {
+		maxDelay = 30 * time.Second
+	}
+
+	return []retry.Option{
+		retry.Attempts(attempts),
+		retry.Delay(delay),
+		retry.DelayType(func(n uint, err error, config *retry.Config) time.Duration {
+			var statusErr *DownloadStatusError
+			if errors.As(err, &statusErr) && statusErr.retryAfter > 0 {
+				return statusErr.retryAfter
+			}
+
+			return min(retry.BackOffDelay(n, err, config), maxDelay)
+		}),
+		retry.LastErrorOnly(true),
+		retry.RetryIf(func(err error) bool {
+			return ctx.Err() == nil && downloadRetryable(err)
+		}),
+		retry.Context(ctx),
+	}
+}
+
+// downloadFileName returns the file name of the URL.
+func downloadFileName(url string) string {
+	if u, err := neturl.Parse(url); err == nil {
+		return pathpkg.Base(u.Path)
+	}
+
+	return pathpkg.Base(url)
+}
+
+// newRequest returns a GET request for the URL with the given context and the headers and authentication of the options.
+func (o DownloadOptions) newRequest(ctx context.Context, url string) (request *http.Request, err error) {
+	return o.newRequestWithMethod(ctx, http.MethodGet, url)
+}
+
+// newRequestWithMethod returns a request with the given method for the URL with the given context and the headers and authentication of the options.
+func (o DownloadOptions) newRequestWithMethod(ctx context.Context, method string, url string) (request *http.Request, err error) {
+	request, err = http.NewRequestWithContext(ctx, method, url, nil)
 	if err != nil {
-		return err
+		return nil, err
+	}
+	for name, values := range o.Headers {
+		for _, value := range values {
+			request.Header.Add(name, value)
+		}
+	}
+	if o.Authenticate != nil {
+		if err := o.Authenticate(request); err != nil {
+			return nil, err
+		}
+	}
+
+	return request, nil
+}
+
+// downloadBytes downloads the contents of the URL.
+func downloadBytes(ctx context.Context, url string, options DownloadOptions) (contents []byte, err error) {
+	request, err := options.newRequest(ctx, url)
+	if err != nil {
+		return nil, err
+	}
+	response, err := options.Client.Do(request)
+	if err != nil {
+		return nil, err
 	}
 	defer func() {
-		if e := out.Close(); e != nil {
+		if e := response.Body.Close(); e != nil {
 			err = errors.Join(err, e)
 		}
 	}()
 
-	_, err = io.Copy(out, resp.Body)
+	if response.StatusCode != http.StatusOK {
+		return nil, newDownloadStatusError(response)
+	}
 
-	return err
+	return io.ReadAll(response.Body)
 }
 
-// DownloadFileWithProgress downloads a file from the URL to the file path while printing a progress to STDOUT.
-func DownloadFileWithProgress(url string, filePath string) (err error) {
-	request, err := http.NewRequest("GET", url, nil)
+// DownloadStatusError holds an error for a download that failed with an unexpected status code.
+type DownloadStatusError struct {
+	// URL holds the requested URL.
+	URL string
+	// StatusCode holds the status code of the response.
+	StatusCode int
+	// Status holds the status of the response.
+	Status string
+	// Body holds the beginning of the body of the response.
+	Body string
+
+	// retryAfter holds the delay the server requested before the next request.
+	retryAfter time.Duration
+}
+
+// downloadStatusErrorBodyLimit holds the maximum number of bytes of a response body that is kept for an error.
+const downloadStatusErrorBodyLimit = 512
+
+// newDownloadStatusError returns an error for the given response with an unexpected status code.
+func newDownloadStatusError(response *http.Response) *DownloadStatusError {
+	body, _ := io.ReadAll(io.LimitReader(response.Body, downloadStatusErrorBodyLimit))
+
+	return &DownloadStatusError{
+		URL:        response.Request.URL.String(),
+		StatusCode: response.StatusCode,
+		Status:     response.Status,
+		Body:       strings.TrimSpace(string(body)),
+
+		retryAfter: parseRetryAfter(response.Header.Get("Retry-After")),
+	}
+}
+
+// Error returns the error message.
+func (e *DownloadStatusError) Error() string {
+	if e.Body == "" {
+		return fmt.Sprintf("downloading file %q failed with status code %d: %s", e.URL, e.StatusCode, e.Status)
+	}
+
+	return fmt.Sprintf("downloading file %q failed with status code %d: %s: %s", e.URL, e.StatusCode, e.Status, e.Body)
+}
+
+// parseRetryAfter returns the delay of a "Retry-After" header, which holds either seconds or a date.
+func parseRetryAfter(value string) (delay time.Duration) {
+	if value == "" {
+		return 0
+	}
+
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0
+		}
+
+		return time.Duration(seconds) * time.Second
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		return max(time.Until(date), 0)
+	}
+
+	return 0
+}
+
+// downloadRetryable returns if a download that failed with the given error should be retried.
+func downloadRetryable(err error) bool {
+	var statusErr *DownloadStatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.StatusCode == http.StatusRequestTimeout || statusErr.StatusCode == http.StatusTooManyRequests || (statusErr.StatusCode >= 500 && statusErr.StatusCode != http.StatusNotImplemented)
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+
+	return errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.ECONNABORTED) || errors.Is(err, io.ErrUnexpectedEOF)
+}
+
+// downloadPartFileSuffix holds the suffix of the file a download is written to until it is complete.
+const downloadPartFileSuffix = ".part"
+
+// downloadPartStateFileSuffix holds the suffix of the file that holds the state of an incomplete download.
+const downloadPartStateFileSuffix = ".part.json"
+
+// downloadPartState holds the state of an incomplete download, which is needed to resume it.
+type downloadPartState struct {
+	// URL holds the URL of the download.
+	URL string `json:"url"`
+	// ETag holds the entity tag of the downloaded file.
+	ETag string `json:"etag,omitempty"`
+	// LastModified holds the last modification time of the downloaded file as sent by the server.
+	LastModified string `json:"lastModified,omitempty"`
+}
+
+// validator returns the validator for an "If-Range" header, which is empty if the download cannot be resumed safely.
+func (s *downloadPartState) validator() string {
+	// Weak entity tags must not be used for range requests.
+	if s.ETag != "" && !strings.HasPrefix(s.ETag, "W/") {
+		return s.ETag
+	}
+
+	return s.LastModified
+}
+
+// downloadFile downloads a file from the URL to the file path once.
+func downloadFile(ctx context.Context, url string, filePath string, options DownloadOptions) (err error) {
+	partFilePath := filePath + downloadPartFileSuffix
+	stateFilePath := filePath + downloadPartStateFileSuffix
+
+	request, err := options.newRequest(ctx, url)
 	if err != nil {
 		return err
 	}
+	offset, validator := downloadResumeOffset(url, partFilePath, stateFilePath)
+	if offset > 0 {
+		request.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+		request.Header.Set("If-Range", validator)
+	}
 
-	response, err := HTTPClient.Do(request)
+	response, err := options.Client.Do(request)
 	if err != nil {
 		return err
 	}
 	defer func() {
 		if e := response.Body.Close(); e != nil {
-			if err != nil {
-				err = errors.Join(err, e)
-			} else {
-				err = e
-			}
+			err = errors.Join(err, e)
 		}
 	}()
-	if response.StatusCode != http.StatusOK {
-		return fmt.Errorf("downloading file failed with status code %d: %s", response.StatusCode, response.Status)
+
+	switch response.StatusCode {
+	case http.StatusOK:
+		// The server sends the whole file if it does not support range requests or if the file changed.
+		offset = 0
+	case http.StatusPartialContent:
+		var start int64
+		if _, err := fmt.Sscanf(response.Header.Get("Content-Range"), "bytes %d-", &start); err != nil || offset == 0 || start != offset {
+			return fmt.Errorf("downloading file failed because of an unexpected content range %q", response.Header.Get("Content-Range"))
+		}
+	case http.StatusRequestedRangeNotSatisfiable:
+		if offset == 0 {
+			// The download has already been restarted without a range, so restarting again would not help.
+			return newDownloadStatusError(response)
+		}
+
+		// The incomplete download is not a prefix of the file anymore, so start from scratch.
+		if err := downloadRemovePartFiles(partFilePath, stateFilePath); err != nil {
+			return err
+		}
+
+		return downloadFile(ctx, url, filePath, options)
+	default:
+		return newDownloadStatusError(response)
+	}
+
+	if offset == 0 {
+		if err := downloadWritePartState(stateFilePath, &downloadPartState{
+			URL:          url,
+			ETag:         response.Header.Get("ETag"),
+			LastModified: response.Header.Get("Last-Modified"),
+		}); err != nil {
+			return err
+		}
+	}
+
+	var hash hash.Hash
+	if options.Checksum != "" {
+		if hash, err = options.ChecksumAlgorithm.New(); err != nil {
+			return err
+		}
+	}
+	if err := downloadWritePartFile(partFilePath, offset, response, options.ProgressWriter, hash); err != nil {
+		return err
+	}
+	if hash != nil {
+		if actual := hex.EncodeToString(hash.Sum(nil)); !strings.EqualFold(actual, options.Checksum) {
+			return errors.Join(&ChecksumMismatchError{
+				Algorithm: options.ChecksumAlgorithm,
+				Expected:  strings.ToLower(options.Checksum),
+				Actual:    actual,
+			}, downloadRemovePartFiles(partFilePath, stateFilePath))
+		}
+	}
+
+	if err := os.Rename(partFilePath, filePath); err != nil {
+		return err
+	}
+	if err := os.Remove(stateFilePath); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	return nil
+}
+
+// downloadResumeOffset returns the offset and the validator to resume an incomplete download of the given URL, or a zero offset if it cannot be resumed.
+func downloadResumeOffset(url string, partFilePath string, stateFilePath string) (offset int64, validator string) {
+	info, err := os.Stat(partFilePath)
+	if err != nil || info.Size() == 0 {
+		return 0, ""
+	}
+
+	data, err := os.ReadFile(stateFilePath)
+	if err != nil {
+		return 0, ""
+	}
+	var state downloadPartState
+	if err := json.Unmarshal(data, &state); err != nil || state.URL != url {
+		return 0, ""
+	}
+	validator = state.validator()
+	if validator == "" {
+		return 0, ""
+	}
+
+	return info.Size(), validator
+}
+
+// downloadWritePartState writes the state of a download so it can be resumed, or removes the state if the download cannot be resumed.
+func downloadWritePartState(stateFilePath string, state *downloadPartState) (err error) {
+	if state.validator() == "" {
+		if err := os.Remove(stateFilePath); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+
+		return nil
 	}
 
-	file, err := os.OpenFile(filePath, os.O_CREATE|os.O_WRONLY, 0644)
+	data, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(stateFilePath, data, 0644)
+}
+
+// downloadWritePartFile writes the body of the response to the part file starting at the given offset. The part file is truncated if the offset is zero.
+// The whole contents of the part file are written to the given hash, if there is one.
+func downloadWritePartFile(partFilePath string, offset int64, response *http.Response, progressWriter io.Writer, hash hash.Hash) (err error) {
+	if hash != nil && offset > 0 {
+		if err := downloadHashPartFile(partFilePath, offset, hash); err != nil {
+			return err
+		}
+	}
+
+	flags := os.O_CREATE | os.O_WRONLY
+	if offset > 0 {
+		flags |= os.O_APPEND
+	} else {
+		flags |= os.O_TRUNC
+	}
+	file, err := os.OpenFile(partFilePath, flags, 0644)
 	if err != nil {
 		return err
 	}
 	defer func() {
 		if e := file.Close(); e != nil {
-			if err != nil {
-				err = errors.Join(err, e)
-			} else {
-				err = e
-			}
+			err = errors.Join(err, e)
 		}
 	}()
 
-	pg := ProgressBarBytes(os.Stdout, int(response.ContentLength), "downloading")
-	defer func() {
-		if e := pg.Close(); e != nil {
-			if err != nil {
+	var writer io.Writer = file
+	if hash != nil {
+		writer = io.MultiWriter(writer, hash)
+	}
+	if progressWriter != nil {
+		length := response.ContentLength
+		if length >= 0 {
+			length += offset
+		}
+		pg := ProgressBarBytes(progressWriter, int(length), "downloading")
+		defer func() {
+			if e := pg.Close(); e != nil {
 				err = errors.Join(err, e)
-			} else {
-				err = e
+			}
+		}()
+		if offset > 0 {
+			if err := pg.Set64(offset); err != nil {
+				return err
 			}
 		}
+
+		writer = io.MultiWriter(writer, pg)
+	}
+
+	if _, err := io.Copy(writer, response.Body); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// downloadHashPartFile writes the contents of the part file up to the given offset to the hash.
+func downloadHashPartFile(partFilePath string, offset int64, hash hash.Hash) (err error) {
+	file, err := os.Open(partFilePath)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if e := file.Close(); e != nil {
+			err = errors.Join(err, e)
+		}
 	}()
 
-	if _, err := io.Copy(io.MultiWriter(file, pg), response.Body); err != nil {
+	if _, err := io.CopyN(hash, file, offset); err != nil {
 		return err
 	}
 
 	return nil
 }
+
+// downloadRemovePartFiles removes the files of an incomplete download.
+func downloadRemovePartFiles(partFilePath string, stateFilePath string) (err error) {
+	for _, filePath := range []string{partFilePath, stateFilePath} {
+		if e := os.Remove(filePath); e != nil && !errors.Is(e, os.ErrNotExist) {
+			err = errors.Join(err, e)
+		}
+	}
+
+	return err
+}
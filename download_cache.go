@@ -0,0 +1,174 @@
+package osutil
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/avast/retry-go"
+)
+
+// downloadCacheObjectType holds the cache object type of cached downloads.
+const downloadCacheObjectType = CacheObjectType("download")
+
+// downloadCacheEntry holds the cached download of a URL.
+type downloadCacheEntry struct {
+	// URL holds the downloaded URL.
+	URL string
+	// ETag holds the entity tag of the download.
+	ETag string
+	// LastModified holds the last modification time of the download as sent by the server.
+	LastModified string
+	// Contents holds the contents of the download.
+	Contents []byte
+	// Validated holds the time the contents have been downloaded or validated with the server the last time.
+	Validated time.Time
+}
+
+// DownloadWithCache downloads the contents of the URL with conditional requests, so the contents are only transferred if they changed since they have been stored in the given cache.
+// If the server cannot be reached or responds with a server error, cached contents that have been validated within the given maximum age are returned instead, which allows using the contents offline. A zero maximum age disables this.
+// If there is an expected checksum, it is verified for cached contents as well.
+func DownloadWithCache(ctx context.Context, url string, cache Cache, maxAge time.Duration, options DownloadOptions) (contents []byte, err error) {
+	if err := options.prepare(ctx, url); err != nil {
+		return nil, err
+	}
+
+	var entry downloadCacheEntry
+	cached, err := cache.ObjectRead(url, downloadCacheObjectType, &entry)
+	if err != nil {
+		return nil, err
+	}
+	if cached && entry.URL != url {
+		cached = false
+	}
+
+	var notModified bool
+	if err := retry.Do(
+		func() (err error) {
+			contents, notModified, err = downloadConditional(ctx, url, &entry, cached, options)
+
+			return err
+		},
+		options.retryOptions(ctx)...,
+	); err != nil {
+		if cached && maxAge > 0 && time.Since(entry.Validated) <= maxAge && ctx.Err() == nil && downloadUnavailable(err) {
+			if err := downloadVerifyContents(entry.Contents, options); err != nil {
+				return nil, err
+			}
+
+			return entry.Contents, nil
+		}
+
+		return nil, err
+	}
+	if notModified {
+		contents = entry.Contents
+	}
+
+	if err := downloadVerifyContents(contents, options); err != nil {
+		return nil, err
+	}
+
+	entry.URL = url
+	entry.Contents = contents
+	entry.Validated = time.Now()
+	if err := cache.ObjectWrite(url, downloadCacheObjectType, &entry, map[string]string{
+		"url":          url,
+		"etag":         entry.ETag,
+		"lastModified": entry.LastModified,
+	}); err != nil {
+		return nil, err
+	}
+
+	return contents, nil
+}
+
+// downloadConditional downloads the contents of the URL if they differ from the given cache entry, and updates the validators of the entry.
+func downloadConditional(ctx context.Context, url string, entry *downloadCacheEntry, cached bool, options DownloadOptions) (contents []byte, notModified bool, err error) {
+	request, err := options.newRequest(ctx, url)
+	if err != nil {
+		return nil, false, err
+	}
+	if cached {
+		if entry.ETag != "" {
+			request.Header.Set("If-None-Match", entry.ETag)
+		}
+		if entry.LastModified != "" {
+			request.Header.Set("If-Modified-Since", entry.LastModified)
+		}
+	}
+
+	response, err := options.Client.Do(request)
+	if err != nil {
+		return nil, false, err
+	}
+	defer func() {
+		if e := response.Body.Close(); e != nil {
+			err = errors.Join(err, e)
+		}
+	}()
+
+	switch {
+	case response.StatusCode == http.StatusNotModified && cached:
+		return nil, true, nil
+	case response.StatusCode == http.StatusOK:
+	default:
+		return nil, false, newDownloadStatusError(response)
+	}
+
+	if contents, err = io.ReadAll(response.Body); err != nil {
+		return nil, false, err
+	}
+	entry.ETag = response.Header.Get("ETag")
+	entry.LastModified = response.Header.Get("Last-Modified")
+
+	return contents, false, nil
+}
+
+// downloadVerifyContents returns an error if the contents do not match the expected checksum of the options.
+func downloadVerifyContents(contents []byte, options DownloadOptions) (err error) {
+	if options.Checksum == "" {
+		return nil
+	}
+
+	hash, err := options.ChecksumAlgorithm.New()
+	if err != nil {
+		return err
+	}
+	if _, err := hash.Write(contents); err != nil {
+		return err
+	}
+	if actual := hex.EncodeToString(hash.Sum(nil)); !strings.EqualFold(actual, options.Checksum) {
+		return &ChecksumMismatchError{
+			Algorithm: options.ChecksumAlgorithm,
+			Expected:  strings.ToLower(options.Checksum),
+			Actual:    actual,
+		}
+	}
+
+	return nil
+}
+
+// downloadUnavailable returns if a download failed because the server cannot be reached, i.e. because of a network error, a refused connection or a failed DNS lookup, or has a server error.
+func downloadUnavailable(err error) bool {
+	var statusErr *DownloadStatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.StatusCode >= 500
+	}
+
+	// REMARK Every error of an HTTP client implements "net.Error", so only timeouts are taken from it.
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+	var opErr *net.OpError
+	var dnsErr *net.DNSError
+
+	return errors.As(err, &opErr) || errors.As(err, &dnsErr) || errors.Is(err, syscall.ECONNREFUSED)
+}
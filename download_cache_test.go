@@ -0,0 +1,214 @@
+package osutil
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDownloadWithCache(t *testing.T) {
+	var lock sync.Mutex
+	contents := "v1"
+	var statusCodes []int
+	var conditional []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lock.Lock()
+		defer lock.Unlock()
+
+		conditional = append(conditional, r.Header.Get("If-None-Match"))
+		etag := `"` + contents + `"`
+		w.Header().Set("ETag", etag)
+		if r.Header.Get("If-None-Match") == etag {
+			w.WriteHeader(http.StatusNotModified)
+			statusCodes = append(statusCodes, http.StatusNotModified)
+
+			return
+		}
+		_, _ = w.Write([]byte(contents))
+		statusCodes = append(statusCodes, http.StatusOK)
+	}))
+	defer server.Close()
+
+	cache := NewInMemoryCache()
+	options := DownloadOptions{
+		Attempts: 1,
+	}
+
+	actual, err := DownloadWithCache(context.Background(), server.URL, cache, time.Hour, options)
+	require.NoError(t, err)
+	assert.Equal(t, "v1", string(actual))
+
+	actual, err = DownloadWithCache(context.Background(), server.URL, cache, time.Hour, options)
+	require.NoError(t, err)
+	assert.Equal(t, "v1", string(actual))
+
+	lock.Lock()
+	contents = "v2"
+	lock.Unlock()
+	actual, err = DownloadWithCache(context.Background(), server.URL, cache, time.Hour, options)
+	require.NoError(t, err)
+	assert.Equal(t, "v2", string(actual))
+
+	assert.Equal(t, []int{http.StatusOK, http.StatusNotModified, http.StatusOK}, statusCodes)
+	assert.Equal(t, []string{"", `"v1"`, `"v1"`}, conditional)
+
+	// Cached contents are used offline within their maximum age.
+	server.Close()
+	actual, err = DownloadWithCache(context.Background(), server.URL, cache, time.Hour, options)
+	require.NoError(t, err)
+	assert.Equal(t, "v2", string(actual))
+
+	// Cached contents are verified offline as well.
+	digest := sha256.Sum256([]byte("v2"))
+	actual, err = DownloadWithCache(context.Background(), server.URL, cache, time.Hour, DownloadOptions{
+		Attempts: 1,
+		Checksum: hex.EncodeToString(digest[:]),
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "v2", string(actual))
+	digest = sha256.Sum256([]byte("v1"))
+	_, err = DownloadWithCache(context.Background(), server.URL, cache, time.Hour, DownloadOptions{
+		Attempts: 1,
+		Checksum: hex.EncodeToString(digest[:]),
+	})
+	var checksumErr *ChecksumMismatchError
+	assert.ErrorAs(t, err, &checksumErr)
+
+	_, err = DownloadWithCache(context.Background(), server.URL, cache, 0, options)
+	assert.Error(t, err)
+
+	// Cached contents that are older than their maximum age are not used offline.
+	var entry downloadCacheEntry
+	exists, err := cache.ObjectRead(server.URL, downloadCacheObjectType, &entry)
+	require.NoError(t, err)
+	require.True(t, exists)
+	entry.Validated = time.Now().Add(-2 * time.Hour)
+	require.NoError(t, cache.ObjectWrite(server.URL, downloadCacheObjectType, &entry, nil))
+	_, err = DownloadWithCache(context.Background(), server.URL, cache, time.Hour, options)
+	assert.Error(t, err)
+}
+
+func TestDownloadWithCacheLastModified(t *testing.T) {
+	lastModified := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC).Format(http.TimeFormat)
+	var lock sync.Mutex
+	var statusCodes []int
+	var conditional []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lock.Lock()
+		defer lock.Unlock()
+
+		conditional = append(conditional, r.Header.Get("If-Modified-Since"))
+		w.Header().Set("Last-Modified", lastModified)
+		if r.Header.Get("If-Modified-Since") == lastModified {
+			w.WriteHeader(http.StatusNotModified)
+			statusCodes = append(statusCodes, http.StatusNotModified)
+
+			return
+		}
+		_, _ = w.Write([]byte("v1"))
+		statusCodes = append(statusCodes, http.StatusOK)
+	}))
+	defer server.Close()
+
+	cache := NewInMemoryCache()
+	for i := 0; i < 2; i++ {
+		actual, err := DownloadWithCache(context.Background(), server.URL, cache, time.Hour, DownloadOptions{
+			Attempts: 1,
+		})
+		require.NoError(t, err)
+		assert.Equal(t, "v1", string(actual))
+	}
+
+	assert.Equal(t, []int{http.StatusOK, http.StatusNotModified}, statusCodes)
+	assert.Equal(t, []string{"", lastModified}, conditional)
+}
+
+func TestDownloadUnavailable(t *testing.T) {
+	type testCase struct {
+		Name string
+
+		Error error
+
+		ExpectedUnavailable bool
+	}
+
+	validate := func(t *testing.T, tc *testCase) {
+		t.Run(tc.Name, func(t *testing.T) {
+			assert.Equal(t, tc.ExpectedUnavailable, downloadUnavailable(tc.Error))
+		})
+	}
+
+	validate(t, &testCase{
+		Name: "Server error",
+
+		Error: &DownloadStatusError{
+			StatusCode: http.StatusServiceUnavailable,
+		},
+
+		ExpectedUnavailable: true,
+	})
+	validate(t, &testCase{
+		Name: "Client error",
+
+		Error: &DownloadStatusError{
+			StatusCode: http.StatusNotFound,
+		},
+	})
+	validate(t, &testCase{
+		Name: "Connection refused",
+
+		Error: &url.Error{
+			Op:  "Get",
+			URL: "http://localhost",
+			Err: &net.OpError{
+				Op:  "dial",
+				Net: "tcp",
+				Err: os.NewSyscallError("connect", syscall.ECONNREFUSED),
+			},
+		},
+
+		ExpectedUnavailable: true,
+	})
+	validate(t, &testCase{
+		Name: "DNS",
+
+		Error: &url.Error{
+			Op:  "Get",
+			URL: "http://unknown.invalid",
+			Err: &net.DNSError{
+				Err:        "no such host",
+				Name:       "unknown.invalid",
+				IsNotFound: true,
+			},
+		},
+
+		ExpectedUnavailable: true,
+	})
+	validate(t, &testCase{
+		Name: "Certificate",
+
+		Error: &url.Error{
+			Op:  "Get",
+			URL: "https://localhost",
+			Err: x509.UnknownAuthorityError{},
+		},
+	})
+	validate(t, &testCase{
+		Name: "Checksum mismatch",
+
+		Error: &ChecksumMismatchError{},
+	})
+}
@@ -0,0 +1,237 @@
+package osutil
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/avast/retry-go"
+)
+
+// DownloadAndExtract downloads an archive from the URL and extracts it to the destination path without storing the archive on disk. The download is canceled when the given context is done.
+// The format of the archive, i.e. a TAR file that is optionally compressed with gzip or XZ, or a zip file, is detected by the magic bytes of the archive, and otherwise by the file name and content type the server sends.
+// Zip files are stored in a temporary file while they are extracted, since their directory is at their end.
+// The archive is extracted into a temporary directory next to the destination path first, whose files are only moved to the destination path if the extraction succeeded and the digest of the archive matches the expected checksum. Otherwise, e.g. if a "*ChecksumMismatchError" is returned, the extracted files are removed.
+// Only requests are retried, since an extraction cannot be resumed.
+func DownloadAndExtract(ctx context.Context, url string, destinationPath string, options DownloadOptions) (err error) {
+	if err := options.prepare(ctx, url); err != nil {
+		return err
+	}
+
+	var response *http.Response
+	if err := retry.Do(
+		func() (err error) {
+			request, err := options.newRequest(ctx, url)
+			if err != nil {
+				return err
+			}
+			response, err = options.Client.Do(request)
+			if err != nil {
+				return err
+			}
+			if response.StatusCode != http.StatusOK {
+				err := newDownloadStatusError(response)
+
+				return errors.Join(err, response.Body.Close())
+			}
+
+			return nil
+		},
+		options.retryOptions(ctx)...,
+	); err != nil {
+		return err
+	}
+	defer func() {
+		if e := response.Body.Close(); e != nil {
+			err = errors.Join(err, e)
+		}
+	}()
+
+	destinationPath = filepath.Clean(destinationPath)
+	if err := os.MkdirAll(filepath.Dir(destinationPath), 0755); err != nil {
+		return err
+	}
+	temporaryPath, err := os.MkdirTemp(filepath.Dir(destinationPath), "."+filepath.Base(destinationPath)+".download-")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if e := os.RemoveAll(temporaryPath); e != nil {
+			err = errors.Join(err, e)
+		}
+	}()
+	extractedPath := filepath.Join(temporaryPath, "extracted")
+	if err := os.Mkdir(extractedPath, 0755); err != nil {
+		return err
+	}
+
+	var stream io.Reader = response.Body
+	var hash hash.Hash
+	if options.Checksum != "" {
+		if hash, err = options.ChecksumAlgorithm.New(); err != nil {
+			return err
+		}
+		stream = io.TeeReader(stream, hash)
+	}
+	if options.ProgressWriter != nil {
+		pg := ProgressBarBytes(options.ProgressWriter, int(response.ContentLength), "downloading")
+		defer func() {
+			if e := pg.Close(); e != nil {
+				err = errors.Join(err, e)
+			}
+		}()
+		stream = io.TeeReader(stream, pg)
+	}
+
+	buffered := bufio.NewReader(stream)
+	// Missing bytes are not an error, as small archives are detected by their names.
+	header, _ := buffered.Peek(archiveMagicBytesLength)
+	compressionType, isZip, err := detectArchiveFormat(header, downloadResponseFileName(response), response.Header.Get("Content-Type"))
+	if err != nil {
+		return err
+	}
+
+	if isZip {
+		if err := downloadExtractZip(buffered, filepath.Join(temporaryPath, "archive.zip"), extractedPath); err != nil {
+			return err
+		}
+	} else if err := TarExtract(buffered, extractedPath, compressionType); err != nil {
+		return err
+	}
+
+	if hash != nil {
+		// Archives can have trailing bytes that are not needed for the extraction, but are part of the digest.
+		if _, err := io.Copy(io.Discard, buffered); err != nil {
+			return err
+		}
+
+		if actual := hex.EncodeToString(hash.Sum(nil)); !strings.EqualFold(actual, options.Checksum) {
+			return &ChecksumMismatchError{
+				Algorithm: options.ChecksumAlgorithm,
+				Expected:  strings.ToLower(options.Checksum),
+				Actual:    actual,
+			}
+		}
+	}
+
+	return downloadMoveExtracted(extractedPath, destinationPath)
+}
+
+// downloadExtractZip stores the zip archive of the stream in the given archive file and extracts it to the destination path.
+func downloadExtractZip(stream io.Reader, archiveFilePath string, destinationPath string) (err error) {
+	file, err := os.Create(archiveFilePath)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if e := file.Close(); e != nil {
+			err = errors.Join(err, e)
+		}
+	}()
+
+	size, err := io.Copy(file, stream)
+	if err != nil {
+		return err
+	}
+	archive, err := zip.NewReader(file, size)
+	if err != nil {
+		return err
+	}
+
+	return zipExtract(archive, destinationPath)
+}
+
+// downloadMoveExtracted moves the extracted files of the source path to the destination path. Directories that exist already are merged and files that exist already are replaced.
+func downloadMoveExtracted(sourcePath string, destinationPath string) (err error) {
+	if _, err := os.Lstat(destinationPath); errors.Is(err, os.ErrNotExist) {
+		return os.Rename(sourcePath, destinationPath)
+	}
+
+	entries, err := os.ReadDir(sourcePath)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		source := filepath.Join(sourcePath, entry.Name())
+		destination := filepath.Join(destinationPath, entry.Name())
+
+		if entry.IsDir() {
+			if info, err := os.Lstat(destination); err == nil && info.IsDir() {
+				if err := downloadMoveExtracted(source, destination); err != nil {
+					return err
+				}
+
+				continue
+			}
+		}
+
+		if err := os.Rename(source, destination); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// archiveMagicBytesLength holds the number of bytes that are needed to detect the format of an archive.
+const archiveMagicBytesLength = 262
+
+// detectArchiveFormat returns the format of an archive by its first bytes, and otherwise by its file name and content type.
+func detectArchiveFormat(header []byte, fileName string, contentType string) (compressionType CompressionType, isZip bool, err error) {
+	switch {
+	case bytes.HasPrefix(header, []byte("PK\x03\x04")) || bytes.HasPrefix(header, []byte("PK\x05\x06")):
+		return CompressionTypeNone, true, nil
+	case bytes.HasPrefix(header, []byte{0x1f, 0x8b}):
+		return CompressionTypeGNUZipped, false, nil
+	case bytes.HasPrefix(header, []byte{0xfd, '7', 'z', 'X', 'Z', 0x00}):
+		return CompressionTypeXZ, false, nil
+	case len(header) >= archiveMagicBytesLength && string(header[257:262]) == "ustar":
+		return CompressionTypeNone, false, nil
+	}
+
+	fileName = strings.ToLower(fileName)
+	switch {
+	case strings.HasSuffix(fileName, ".zip"):
+		return CompressionTypeNone, true, nil
+	case strings.HasSuffix(fileName, ".tar.gz") || strings.HasSuffix(fileName, ".tgz"):
+		return CompressionTypeGNUZipped, false, nil
+	case strings.HasSuffix(fileName, ".tar.xz") || strings.HasSuffix(fileName, ".txz"):
+		return CompressionTypeXZ, false, nil
+	case strings.HasSuffix(fileName, ".tar"):
+		return CompressionTypeNone, false, nil
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	switch mediaType {
+	case "application/zip", "application/x-zip-compressed":
+		return CompressionTypeNone, true, nil
+	case "application/gzip", "application/x-gzip":
+		return CompressionTypeGNUZipped, false, nil
+	case "application/x-xz":
+		return CompressionTypeXZ, false, nil
+	case "application/x-tar":
+		return CompressionTypeNone, false, nil
+	}
+
+	return CompressionTypeNone, false, fmt.Errorf("unknown archive format of %q with content type %q", fileName, contentType)
+}
+
+// downloadResponseFileName returns the file name of a response, which is either sent by the server or taken from the requested URL.
+func downloadResponseFileName(response *http.Response) string {
+	if _, parameters, err := mime.ParseMediaType(response.Header.Get("Content-Disposition")); err == nil && parameters["filename"] != "" {
+		return parameters["filename"]
+	}
+
+	return downloadFileName(response.Request.URL.String())
+}
@@ -0,0 +1,183 @@
+package osutil
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/ulikunitz/xz"
+)
+
+func TestDownloadAndExtract(t *testing.T) {
+	files := map[string]string{
+		"bin/tool":  "#!/bin/sh\n",
+		"README.md": "# Tool\n",
+	}
+
+	var tarArchive bytes.Buffer
+	tarWriter := tar.NewWriter(&tarArchive)
+	for _, name := range []string{"README.md", "bin/tool"} {
+		require.NoError(t, tarWriter.WriteHeader(&tar.Header{
+			Name: name,
+			Mode: 0755,
+			Size: int64(len(files[name])),
+		}))
+		_, err := tarWriter.Write([]byte(files[name]))
+		require.NoError(t, err)
+	}
+	require.NoError(t, tarWriter.Close())
+
+	var tarGzArchive bytes.Buffer
+	gzipWriter := gzip.NewWriter(&tarGzArchive)
+	_, err := gzipWriter.Write(tarArchive.Bytes())
+	require.NoError(t, err)
+	require.NoError(t, gzipWriter.Close())
+
+	var tarXZArchive bytes.Buffer
+	xzWriter, err := xz.NewWriter(&tarXZArchive)
+	require.NoError(t, err)
+	_, err = xzWriter.Write(tarArchive.Bytes())
+	require.NoError(t, err)
+	require.NoError(t, xzWriter.Close())
+
+	var zipArchive bytes.Buffer
+	zipWriter := zip.NewWriter(&zipArchive)
+	for _, name := range []string{"README.md", "bin/tool"} {
+		w, err := zipWriter.Create(name)
+		require.NoError(t, err)
+		_, err = w.Write([]byte(files[name]))
+		require.NoError(t, err)
+	}
+	require.NoError(t, zipWriter.Close())
+
+	var escapingZipArchive bytes.Buffer
+	zipWriter = zip.NewWriter(&escapingZipArchive)
+	for _, name := range []string{"README.md", "../escaped"} {
+		w, err := zipWriter.Create(name)
+		require.NoError(t, err)
+		_, err = w.Write([]byte("escaped"))
+		require.NoError(t, err)
+	}
+	require.NoError(t, zipWriter.Close())
+
+	type testCase struct {
+		Name string
+
+		Archive             []byte
+		Options             DownloadOptions
+		ExistingDestination bool
+
+		ExpectedError string
+	}
+
+	validate := func(t *testing.T, tc *testCase) {
+		t.Run(tc.Name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(tc.Archive))
+			}))
+			defer server.Close()
+			temporaryPath := t.TempDir()
+			destinationPath := filepath.Join(temporaryPath, "destination")
+			if tc.ExistingDestination {
+				require.NoError(t, os.MkdirAll(filepath.Join(destinationPath, "bin"), 0755))
+				require.NoError(t, os.WriteFile(filepath.Join(destinationPath, "bin", "tool"), []byte("outdated"), 0755))
+				require.NoError(t, os.WriteFile(filepath.Join(destinationPath, "LICENSE"), []byte("license"), 0644))
+			}
+
+			err := DownloadAndExtract(context.Background(), server.URL+"/archive", destinationPath, tc.Options)
+			if tc.ExpectedError != "" {
+				assert.ErrorContains(t, err, tc.ExpectedError)
+
+				// Neither extracted nor temporary files must be left.
+				entries, err := os.ReadDir(temporaryPath)
+				require.NoError(t, err)
+				assert.Empty(t, entries)
+
+				return
+			}
+			require.NoError(t, err)
+
+			entries, err := os.ReadDir(temporaryPath)
+			require.NoError(t, err)
+			require.Len(t, entries, 1)
+			assert.Equal(t, "destination", entries[0].Name())
+			if tc.ExistingDestination {
+				assert.FileExists(t, filepath.Join(destinationPath, "LICENSE"))
+			}
+
+			for name, contents := range files {
+				actual, err := os.ReadFile(filepath.Join(destinationPath, filepath.FromSlash(name)))
+				require.NoError(t, err)
+				assert.Equal(t, contents, string(actual))
+			}
+		})
+	}
+
+	digest := sha256.Sum256(tarGzArchive.Bytes())
+
+	validate(t, &testCase{
+		Name: "TAR",
+
+		Archive: tarArchive.Bytes(),
+	})
+	validate(t, &testCase{
+		Name: "GNU zipped TAR",
+
+		Archive: tarGzArchive.Bytes(),
+		Options: DownloadOptions{
+			Checksum: hex.EncodeToString(digest[:]),
+		},
+	})
+	validate(t, &testCase{
+		Name: "XZ compressed TAR",
+
+		Archive: tarXZArchive.Bytes(),
+	})
+	validate(t, &testCase{
+		Name: "Zip",
+
+		Archive: zipArchive.Bytes(),
+	})
+	validate(t, &testCase{
+		Name: "Existing destination",
+
+		Archive:             zipArchive.Bytes(),
+		ExistingDestination: true,
+	})
+	validate(t, &testCase{
+		Name: "Checksum mismatch",
+
+		Archive: tarArchive.Bytes(),
+		Options: DownloadOptions{
+			Checksum: hex.EncodeToString(digest[:]),
+		},
+
+		ExpectedError: "sha256 checksum mismatch",
+	})
+	validate(t, &testCase{
+		Name: "Zip with escaping path",
+
+		Archive: escapingZipArchive.Bytes(),
+
+		ExpectedError: "invalid name",
+	})
+	validate(t, &testCase{
+		Name: "Unknown format",
+
+		Archive: []byte("not an archive"),
+
+		ExpectedError: "unknown archive format",
+	})
+}
@@ -0,0 +1,240 @@
+package osutil
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/avast/retry-go"
+)
+
+// downloadSegmentMinimumSize holds the minimum size of a segment of a segmented download, so small files are not split.
+const downloadSegmentMinimumSize = 1 << 20
+
+// downloadSegmentable returns the size and the validator of the file of the URL if it should be downloaded in segments.
+// The preflight request is retried like a download, so a transient error does not prevent splitting the download.
+func downloadSegmentable(ctx context.Context, url string, options DownloadOptions) (size int64, validator string, ok bool) {
+	if options.Segments < 2 {
+		return 0, "", false
+	}
+
+	var response *http.Response
+	if err := retry.Do(
+		func() (err error) {
+			request, err := options.newRequestWithMethod(ctx, http.MethodHead, url)
+			if err != nil {
+				return err
+			}
+			response, err = options.Client.Do(request)
+			if err != nil {
+				return err
+			}
+			if response.StatusCode != http.StatusOK {
+				err := newDownloadStatusError(response)
+
+				return errors.Join(err, response.Body.Close())
+			}
+
+			return response.Body.Close()
+		},
+		options.retryOptions(ctx)...,
+	); err != nil {
+		return 0, "", false
+	}
+
+	if response.Header.Get("Accept-Ranges") != "bytes" || response.ContentLength < 2*downloadSegmentMinimumSize {
+		return 0, "", false
+	}
+	state := downloadPartState{
+		ETag:         response.Header.Get("ETag"),
+		LastModified: response.Header.Get("Last-Modified"),
+	}
+	validator = state.validator()
+	if validator == "" {
+		// Without a validator, segments could be mixed from different versions of the file.
+		return 0, "", false
+	}
+
+	return response.ContentLength, validator, true
+}
+
+// downloadSegment holds a range of a segmented download.
+type downloadSegment struct {
+	// start holds the offset of the first byte of the segment.
+	start int64
+	// end holds the offset of the last byte of the segment.
+	end int64
+	// written holds the number of bytes of the segment that have been written.
+	written int64
+}
+
+// downloadFileSegmented downloads a file of the given size from the URL to the file path with concurrent range requests.
+// Since the segments are written out of order, the checksum is verified by reading the complete file again after the download.
+func downloadFileSegmented(ctx context.Context, url string, filePath string, size int64, validator string, options DownloadOptions) (err error) {
+	partFilePath := filePath + downloadPartFileSuffix
+	stateFilePath := filePath + downloadPartStateFileSuffix
+
+	// A preallocated part file must never be resumed as if it was an incomplete download.
+	if err := downloadRemovePartFiles(partFilePath, stateFilePath); err != nil {
+		return err
+	}
+
+	if err := func() (err error) {
+		file, err := os.OpenFile(partFilePath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			if e := file.Close(); e != nil {
+				err = errors.Join(err, e)
+			}
+		}()
+		if err := downloadPreallocate(file, size); err != nil {
+			return err
+		}
+
+		var progress io.Writer = io.Discard
+		if options.ProgressWriter != nil {
+			pg := ProgressBarBytes(options.ProgressWriter, int(size), "downloading")
+			defer func() {
+				if e := pg.Close(); e != nil {
+					err = errors.Join(err, e)
+				}
+			}()
+			progress = pg
+		}
+
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+
+		segments := options.Segments
+		if maximum := int(size / downloadSegmentMinimumSize); segments > maximum {
+			segments = maximum
+		}
+		segmentSize := size / int64(segments)
+
+		var errLock sync.Mutex
+		var wg sync.WaitGroup
+		for i := 0; i < segments; i++ {
+			segment := &downloadSegment{
+				start: int64(i) * segmentSize,
+				end:   int64(i+1)*segmentSize - 1,
+			}
+			if i == segments-1 {
+				segment.end = size - 1
+			}
+
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+
+				if e := retry.Do(
+					func() error {
+						return segment.download(ctx, url, validator, file, progress, options)
+					},
+					options.retryOptions(ctx)...,
+				); e != nil {
+					errLock.Lock()
+					// Only the first error is of interest, since it cancels all other segments.
+					if err == nil {
+						err = e
+					}
+					errLock.Unlock()
+
+					// Stop the other segments, since the download failed anyway.
+					cancel()
+				}
+			}()
+		}
+		wg.Wait()
+
+		return err
+	}(); err != nil {
+		return err
+	}
+
+	if options.Checksum != "" {
+		digest, err := checksumForFile(partFilePath, options.ChecksumAlgorithm)
+		if err != nil {
+			return err
+		}
+		if actual := hex.EncodeToString(digest); !strings.EqualFold(actual, options.Checksum) {
+			return errors.Join(&ChecksumMismatchError{
+				Algorithm: options.ChecksumAlgorithm,
+				Expected:  strings.ToLower(options.Checksum),
+				Actual:    actual,
+			}, downloadRemovePartFiles(partFilePath, stateFilePath))
+		}
+	}
+
+	return os.Rename(partFilePath, filePath)
+}
+
+// download downloads the remaining bytes of the segment into the file.
+func (s *downloadSegment) download(ctx context.Context, url string, validator string, file *os.File, progress io.Writer, options DownloadOptions) (err error) {
+	start := s.start + s.written
+	if start > s.end {
+		return nil
+	}
+
+	request, err := options.newRequest(ctx, url)
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", start, s.end))
+	request.Header.Set("If-Range", validator)
+
+	response, err := options.Client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if e := response.Body.Close(); e != nil {
+			err = errors.Join(err, e)
+		}
+	}()
+
+	switch response.StatusCode {
+	case http.StatusPartialContent:
+		var contentStart int64
+		if _, err := fmt.Sscanf(response.Header.Get("Content-Range"), "bytes %d-", &contentStart); err != nil || contentStart != start {
+			return fmt.Errorf("downloading file failed because of an unexpected content range %q", response.Header.Get("Content-Range"))
+		}
+	case http.StatusOK:
+		return errors.New("downloading file failed because the file changed during the download")
+	default:
+		return newDownloadStatusError(response)
+	}
+
+	if _, err := io.Copy(io.MultiWriter(&downloadSegmentWriter{
+		segment: s,
+		file:    file,
+	}, progress), io.LimitReader(response.Body, s.end-start+1)); err != nil {
+		return err
+	}
+	if s.start+s.written <= s.end {
+		return io.ErrUnexpectedEOF
+	}
+
+	return nil
+}
+
+// downloadSegmentWriter writes to the file at the offset of the next byte of a segment.
+type downloadSegmentWriter struct {
+	segment *downloadSegment
+	file    *os.File
+}
+
+// Write writes the given bytes to the file.
+func (w *downloadSegmentWriter) Write(data []byte) (n int, err error) {
+	n, err = w.file.WriteAt(data, w.segment.start+w.segment.written)
+	w.segment.written += int64(n)
+
+	return n, err
+}
@@ -0,0 +1,24 @@
+//go:build linux
+
+package osutil
+
+import (
+	"errors"
+	"os"
+	"syscall"
+)
+
+// downloadPreallocate allocates the given size for the file, so the segments of a download do not fragment the file and a full disk is noticed before the download.
+func downloadPreallocate(file *os.File, size int64) (err error) {
+	err = syscall.Fallocate(int(file.Fd()), 0, 0, size)
+	if err == nil {
+		return nil
+	}
+	if !errors.Is(err, syscall.EOPNOTSUPP) && !errors.Is(err, syscall.ENOSYS) {
+		// Other errors, e.g. a full disk, must not be hidden by a sparse file.
+		return err
+	}
+
+	// Not every file system supports allocating space, so at least set the size of the file.
+	return file.Truncate(size)
+}
@@ -0,0 +1,12 @@
+//go:build !linux
+
+package osutil
+
+import (
+	"os"
+)
+
+// downloadPreallocate sets the size of the file to the given size, so the segments of a download can be written at their offsets.
+func downloadPreallocate(file *os.File, size int64) (err error) {
+	return file.Truncate(size)
+}
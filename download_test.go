@@ -0,0 +1,655 @@
+package osutil
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
+	"encoding/pem"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testDownloadServer holds a server that serves a single file and records the requests it received.
+type testDownloadServer struct {
+	*httptest.Server
+
+	lock sync.Mutex
+	// contents holds the contents of the served file.
+	contents []byte
+	// etag holds the entity tag of the served file.
+	etag string
+	// requests holds the headers of the received requests.
+	requests []http.Header
+}
+
+func newTestDownloadServer(t *testing.T, contents []byte, etag string) *testDownloadServer {
+	s := &testDownloadServer{
+		contents: contents,
+		etag:     etag,
+	}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.lock.Lock()
+		s.requests = append(s.requests, r.Header.Clone())
+		contents := s.contents
+		etag := s.etag
+		s.lock.Unlock()
+
+		if etag != "" {
+			w.Header().Set("ETag", etag)
+		}
+		http.ServeContent(w, r, "file", time.Time{}, bytes.NewReader(contents))
+	}))
+	t.Cleanup(s.Close)
+
+	return s
+}
+
+func TestDownloadFile(t *testing.T) {
+	contents := []byte(strings.Repeat("0123456789", 1000))
+
+	t.Run("Download", func(t *testing.T) {
+		server := newTestDownloadServer(t, contents, `"v1"`)
+		filePath := filepath.Join(t.TempDir(), "file")
+		require.NoError(t, os.WriteFile(filePath, bytes.Repeat([]byte("x"), 2*len(contents)), 0644))
+
+		require.NoError(t, DownloadFile(server.URL, filePath))
+
+		actual, err := os.ReadFile(filePath)
+		require.NoError(t, err)
+		assert.Equal(t, contents, actual)
+		assert.NoFileExists(t, filePath+downloadPartFileSuffix)
+		assert.NoFileExists(t, filePath+downloadPartStateFileSuffix)
+	})
+
+	t.Run("Resume", func(t *testing.T) {
+		server := newTestDownloadServer(t, contents, `"v1"`)
+		filePath := filepath.Join(t.TempDir(), "file")
+		require.NoError(t, os.WriteFile(filePath+downloadPartFileSuffix, contents[:1234], 0644))
+		require.NoError(t, downloadWritePartState(filePath+downloadPartStateFileSuffix, &downloadPartState{
+			URL:  server.URL,
+			ETag: `"v1"`,
+		}))
+
+		require.NoError(t, DownloadFile(server.URL, filePath))
+
+		actual, err := os.ReadFile(filePath)
+		require.NoError(t, err)
+		assert.Equal(t, contents, actual)
+		require.Len(t, server.requests, 1)
+		assert.Equal(t, "bytes=1234-", server.requests[0].Get("Range"))
+		assert.Equal(t, `"v1"`, server.requests[0].Get("If-Range"))
+	})
+
+	t.Run("Changed file", func(t *testing.T) {
+		server := newTestDownloadServer(t, contents, `"v2"`)
+		filePath := filepath.Join(t.TempDir(), "file")
+		require.NoError(t, os.WriteFile(filePath+downloadPartFileSuffix, bytes.Repeat([]byte("x"), 1234), 0644))
+		require.NoError(t, downloadWritePartState(filePath+downloadPartStateFileSuffix, &downloadPartState{
+			URL:  server.URL,
+			ETag: `"v1"`,
+		}))
+
+		require.NoError(t, DownloadFile(server.URL, filePath))
+
+		actual, err := os.ReadFile(filePath)
+		require.NoError(t, err)
+		assert.Equal(t, contents, actual)
+	})
+
+	t.Run("No validator", func(t *testing.T) {
+		server := newTestDownloadServer(t, contents, "")
+		filePath := filepath.Join(t.TempDir(), "file")
+		require.NoError(t, os.WriteFile(filePath+downloadPartFileSuffix, bytes.Repeat([]byte("x"), 1234), 0644))
+
+		require.NoError(t, DownloadFile(server.URL, filePath))
+
+		actual, err := os.ReadFile(filePath)
+		require.NoError(t, err)
+		assert.Equal(t, contents, actual)
+		require.Len(t, server.requests, 1)
+		assert.Empty(t, server.requests[0].Get("Range"))
+	})
+
+	t.Run("Range not satisfiable", func(t *testing.T) {
+		server := newTestDownloadServer(t, contents, `"v1"`)
+		filePath := filepath.Join(t.TempDir(), "file")
+		require.NoError(t, os.WriteFile(filePath+downloadPartFileSuffix, bytes.Repeat([]byte("x"), 2*len(contents)), 0644))
+		require.NoError(t, downloadWritePartState(filePath+downloadPartStateFileSuffix, &downloadPartState{
+			URL:  server.URL,
+			ETag: `"v1"`,
+		}))
+
+		require.NoError(t, DownloadFile(server.URL, filePath))
+
+		actual, err := os.ReadFile(filePath)
+		require.NoError(t, err)
+		assert.Equal(t, contents, actual)
+		assert.Len(t, server.requests, 2)
+	})
+}
+
+func TestDownloadFileWithOptionsRetries(t *testing.T) {
+	contents := []byte(strings.Repeat("0123456789", 1000))
+	options := DownloadOptions{
+		Attempts: 3,
+		Delay:    time.Millisecond,
+	}
+
+	type testCase struct {
+		Name string
+
+		// Handle handles the request with the given number, starting at 1, and returns if it has been handled.
+		Handle func(w http.ResponseWriter, r *http.Request, request int) (handled bool)
+
+		ExpectedRequests int
+		ExpectedError    string
+	}
+
+	validate := func(t *testing.T, tc *testCase) {
+		t.Run(tc.Name, func(t *testing.T) {
+			var requests int
+			var requestsLock sync.Mutex
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				requestsLock.Lock()
+				requests++
+				request := requests
+				requestsLock.Unlock()
+
+				if tc.Handle(w, r, request) {
+					return
+				}
+				w.Header().Set("ETag", `"v1"`)
+				http.ServeContent(w, r, "file", time.Time{}, bytes.NewReader(contents))
+			}))
+			defer server.Close()
+			filePath := filepath.Join(t.TempDir(), "file")
+
+			err := DownloadFileWithOptions(context.Background(), server.URL, filePath, options)
+			assert.Equal(t, tc.ExpectedRequests, requests)
+			if tc.ExpectedError != "" {
+				assert.ErrorContains(t, err, tc.ExpectedError)
+				assert.NoFileExists(t, filePath)
+
+				return
+			}
+			require.NoError(t, err)
+			actual, err := os.ReadFile(filePath)
+			require.NoError(t, err)
+			assert.Equal(t, contents, actual)
+		})
+	}
+
+	validate(t, &testCase{
+		Name: "Server errors",
+
+		Handle: func(w http.ResponseWriter, r *http.Request, request int) (handled bool) {
+			if request > 2 {
+				return false
+			}
+			w.WriteHeader(http.StatusServiceUnavailable)
+
+			return true
+		},
+
+		ExpectedRequests: 3,
+	})
+	validate(t, &testCase{
+		Name: "Too many requests",
+
+		Handle: func(w http.ResponseWriter, r *http.Request, request int) (handled bool) {
+			if request > 1 {
+				return false
+			}
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusTooManyRequests)
+
+			return true
+		},
+
+		ExpectedRequests: 2,
+	})
+	validate(t, &testCase{
+		Name: "Exhausted attempts",
+
+		Handle: func(w http.ResponseWriter, r *http.Request, request int) (handled bool) {
+			w.WriteHeader(http.StatusBadGateway)
+
+			return true
+		},
+
+		ExpectedRequests: 3,
+		ExpectedError:    "status code 502",
+	})
+	validate(t, &testCase{
+		Name: "Not found",
+
+		Handle: func(w http.ResponseWriter, r *http.Request, request int) (handled bool) {
+			http.Error(w, "no such release", http.StatusNotFound)
+
+			return true
+		},
+
+		ExpectedRequests: 1,
+		ExpectedError:    "failed with status code 404: 404 Not Found: no such release",
+	})
+	validate(t, &testCase{
+		Name: "Connection dropped",
+
+		Handle: func(w http.ResponseWriter, r *http.Request, request int) (handled bool) {
+			if request > 1 {
+				assert.Equal(t, "bytes=5000-", r.Header.Get("Range"))
+
+				return false
+			}
+			w.Header().Set("ETag", `"v1"`)
+			w.Header().Set("Content-Length", strconv.Itoa(len(contents)))
+			_, _ = w.Write(contents[:5000])
+			w.(http.Flusher).Flush()
+
+			panic(http.ErrAbortHandler)
+		},
+
+		ExpectedRequests: 2,
+	})
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	assert.Equal(t, time.Duration(0), parseRetryAfter(""))
+	assert.Equal(t, time.Duration(0), parseRetryAfter("soon"))
+	assert.Equal(t, 120*time.Second, parseRetryAfter("120"))
+
+	delay := parseRetryAfter(time.Now().Add(time.Minute).UTC().Format(http.TimeFormat))
+	assert.Greater(t, delay, 50*time.Second)
+	assert.LessOrEqual(t, delay, time.Minute)
+}
+
+func TestDownloadFileWithChecksum(t *testing.T) {
+	contents := []byte(strings.Repeat("0123456789", 1000))
+	digest := sha256.Sum256(contents)
+	expectedDigest := hex.EncodeToString(digest[:])
+
+	t.Run("Valid", func(t *testing.T) {
+		server := newTestDownloadServer(t, contents, `"v1"`)
+		filePath := filepath.Join(t.TempDir(), "file")
+
+		require.NoError(t, DownloadFileWithChecksum(server.URL, filePath, ChecksumAlgorithmSHA256, strings.ToUpper(expectedDigest)))
+
+		actual, err := os.ReadFile(filePath)
+		require.NoError(t, err)
+		assert.Equal(t, contents, actual)
+	})
+
+	t.Run("Resumed", func(t *testing.T) {
+		server := newTestDownloadServer(t, contents, `"v1"`)
+		filePath := filepath.Join(t.TempDir(), "file")
+		require.NoError(t, os.WriteFile(filePath+downloadPartFileSuffix, contents[:1234], 0644))
+		require.NoError(t, downloadWritePartState(filePath+downloadPartStateFileSuffix, &downloadPartState{
+			URL:  server.URL,
+			ETag: `"v1"`,
+		}))
+
+		require.NoError(t, DownloadFileWithChecksum(server.URL, filePath, ChecksumAlgorithmSHA256, expectedDigest))
+		assert.Equal(t, "bytes=1234-", server.requests[0].Get("Range"))
+	})
+
+	t.Run("Mismatch", func(t *testing.T) {
+		server := newTestDownloadServer(t, []byte("tampered"), `"v1"`)
+		filePath := filepath.Join(t.TempDir(), "file")
+
+		err := DownloadFileWithChecksum(server.URL, filePath, ChecksumAlgorithmSHA256, expectedDigest)
+
+		var mismatchErr *ChecksumMismatchError
+		require.ErrorAs(t, err, &mismatchErr)
+		actualDigest := sha256.Sum256([]byte("tampered"))
+		assert.Equal(t, &ChecksumMismatchError{
+			Algorithm: ChecksumAlgorithmSHA256,
+			Expected:  expectedDigest,
+			Actual:    hex.EncodeToString(actualDigest[:]),
+		}, mismatchErr)
+		assert.Len(t, server.requests, 1)
+		assert.NoFileExists(t, filePath)
+		assert.NoFileExists(t, filePath+downloadPartFileSuffix)
+		assert.NoFileExists(t, filePath+downloadPartStateFileSuffix)
+	})
+
+	t.Run("Checksum URL", func(t *testing.T) {
+		mux := http.NewServeMux()
+		mux.HandleFunc("/release/tool.tar.gz", func(w http.ResponseWriter, r *http.Request) {
+			http.ServeContent(w, r, "tool.tar.gz", time.Time{}, bytes.NewReader(contents))
+		})
+		mux.HandleFunc("/release/tool.tar.gz.sha256", func(w http.ResponseWriter, r *http.Request) {
+			_, _ = w.Write([]byte(expectedDigest + "  tool.tar.gz\n"))
+		})
+		server := httptest.NewServer(mux)
+		defer server.Close()
+		filePath := filepath.Join(t.TempDir(), "tool.tar.gz")
+
+		require.NoError(t, DownloadFileWithOptions(context.Background(), server.URL+"/release/tool.tar.gz", filePath, DownloadOptions{
+			ChecksumURL: server.URL + "/release/tool.tar.gz.sha256",
+		}))
+
+		actual, err := os.ReadFile(filePath)
+		require.NoError(t, err)
+		assert.Equal(t, contents, actual)
+	})
+}
+
+func TestDownloadFileWithOptionsRequest(t *testing.T) {
+	t.Run("Headers and authentication", func(t *testing.T) {
+		server := newTestDownloadServer(t, []byte("contents"), "")
+		filePath := filepath.Join(t.TempDir(), "file")
+		var clientRequests int
+		client := &http.Client{
+			Transport: testRoundTripper(func(request *http.Request) (*http.Response, error) {
+				clientRequests++
+
+				return http.DefaultTransport.RoundTrip(request)
+			}),
+		}
+
+		require.NoError(t, DownloadFileWithOptions(context.Background(), server.URL, filePath, DownloadOptions{
+			Client: client,
+			Headers: http.Header{
+				"User-Agent": []string{"osutil-test"},
+			},
+			Authenticate: BasicAuthentication("user", "secret"),
+		}))
+
+		assert.Equal(t, 1, clientRequests)
+		require.Len(t, server.requests, 1)
+		assert.Equal(t, "osutil-test", server.requests[0].Get("User-Agent"))
+		assert.Equal(t, "Basic dXNlcjpzZWNyZXQ=", server.requests[0].Get("Authorization"))
+
+		require.NoError(t, DownloadFileWithOptions(context.Background(), server.URL, filePath, DownloadOptions{
+			Authenticate: BearerAuthentication("token"),
+		}))
+		assert.Equal(t, "Bearer token", server.requests[1].Get("Authorization"))
+	})
+
+	t.Run("Canceled", func(t *testing.T) {
+		release := make(chan struct{})
+		defer close(release)
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Length", "100")
+			_, _ = w.Write([]byte("partial"))
+			w.(http.Flusher).Flush()
+
+			select {
+			case <-release:
+			case <-r.Context().Done():
+			}
+		}))
+		defer server.Close()
+		filePath := filepath.Join(t.TempDir(), "file")
+
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		defer cancel()
+		start := time.Now()
+		err := DownloadFileWithOptions(ctx, server.URL, filePath, DownloadOptions{})
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+		assert.Less(t, time.Since(start), 5*time.Second)
+		assert.NoFileExists(t, filePath)
+	})
+}
+
+// testRoundTripper implements "http.RoundTripper" with a function.
+type testRoundTripper func(request *http.Request) (*http.Response, error)
+
+// RoundTrip executes a single HTTP transaction.
+func (f testRoundTripper) RoundTrip(request *http.Request) (*http.Response, error) {
+	return f(request)
+}
+
+func TestDownloadFileWithOptionsFailure(t *testing.T) {
+	t.Run("Status", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			http.Error(w, "<html>"+strings.Repeat("x", 2*downloadStatusErrorBodyLimit)+"</html>", http.StatusNotFound)
+		}))
+		defer server.Close()
+		filePath := filepath.Join(t.TempDir(), "file")
+
+		err := DownloadFile(server.URL+"/file", filePath)
+
+		var statusErr *DownloadStatusError
+		require.ErrorAs(t, err, &statusErr)
+		assert.Equal(t, server.URL+"/file", statusErr.URL)
+		assert.Equal(t, http.StatusNotFound, statusErr.StatusCode)
+		assert.Len(t, statusErr.Body, downloadStatusErrorBodyLimit)
+		assert.NoFileExists(t, filePath)
+	})
+
+	dropping := func(t *testing.T) (url string) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("ETag", `"v1"`)
+			w.Header().Set("Content-Length", "100")
+			_, _ = w.Write([]byte("partial"))
+			w.(http.Flusher).Flush()
+
+			panic(http.ErrAbortHandler)
+		}))
+		t.Cleanup(server.Close)
+
+		return server.URL
+	}
+
+	t.Run("Remove partial file", func(t *testing.T) {
+		filePath := filepath.Join(t.TempDir(), "file")
+
+		err := DownloadFileWithOptions(context.Background(), dropping(t), filePath, DownloadOptions{
+			Attempts: 1,
+		})
+		assert.ErrorIs(t, err, io.ErrUnexpectedEOF)
+		assert.NoFileExists(t, filePath)
+		assert.NoFileExists(t, filePath+downloadPartFileSuffix)
+		assert.NoFileExists(t, filePath+downloadPartStateFileSuffix)
+	})
+
+	t.Run("Keep partial file", func(t *testing.T) {
+		filePath := filepath.Join(t.TempDir(), "file")
+
+		err := DownloadFileWithOptions(context.Background(), dropping(t), filePath, DownloadOptions{
+			Attempts:        1,
+			KeepPartialFile: true,
+		})
+		assert.ErrorIs(t, err, io.ErrUnexpectedEOF)
+		assert.NoFileExists(t, filePath)
+		assert.FileExists(t, filePath+downloadPartFileSuffix)
+		assert.FileExists(t, filePath+downloadPartStateFileSuffix)
+	})
+
+	t.Run("Range not satisfiable restarts once", func(t *testing.T) {
+		var requests []string
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requests = append(requests, r.Header.Get("Range"))
+			w.WriteHeader(http.StatusRequestedRangeNotSatisfiable)
+		}))
+		defer server.Close()
+		filePath := filepath.Join(t.TempDir(), "file")
+		require.NoError(t, os.WriteFile(filePath+downloadPartFileSuffix, []byte("partial"), 0600))
+		require.NoError(t, downloadWritePartState(filePath+downloadPartStateFileSuffix, &downloadPartState{
+			URL:  server.URL,
+			ETag: `"v1"`,
+		}))
+
+		err := DownloadFileWithOptions(context.Background(), server.URL, filePath, DownloadOptions{
+			Attempts: 1,
+		})
+
+		var statusErr *DownloadStatusError
+		require.ErrorAs(t, err, &statusErr)
+		assert.Equal(t, http.StatusRequestedRangeNotSatisfiable, statusErr.StatusCode)
+		assert.Equal(t, []string{"bytes=7-", ""}, requests)
+		assert.NoFileExists(t, filePath+downloadPartFileSuffix)
+	})
+}
+
+func TestDownloadFileWithOptionsSegments(t *testing.T) {
+	contents := bytes.Repeat([]byte("0123456789abcdef"), 4*downloadSegmentMinimumSize/16+123)
+
+	var lock sync.Mutex
+	var ranges []string
+	failedHead := false
+	failedRange := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodHead {
+			lock.Lock()
+			fail := !failedHead
+			failedHead = true
+			lock.Unlock()
+
+			if fail {
+				w.WriteHeader(http.StatusServiceUnavailable)
+
+				return
+			}
+		} else if r.Method == http.MethodGet {
+			lock.Lock()
+			ranges = append(ranges, r.Header.Get("Range"))
+			fail := !failedRange && r.Header.Get("Range") != "" && !strings.HasPrefix(r.Header.Get("Range"), "bytes=0-")
+			if fail {
+				failedRange = true
+			}
+			lock.Unlock()
+
+			if fail {
+				w.WriteHeader(http.StatusServiceUnavailable)
+
+				return
+			}
+		}
+
+		w.Header().Set("ETag", `"v1"`)
+		http.ServeContent(w, r, "file", time.Time{}, bytes.NewReader(contents))
+	}))
+	defer server.Close()
+	filePath := filepath.Join(t.TempDir(), "file")
+	digest := sha256.Sum256(contents)
+
+	require.NoError(t, DownloadFileWithOptions(context.Background(), server.URL, filePath, DownloadOptions{
+		ProgressWriter: io.Discard,
+		Delay:          time.Millisecond,
+		Segments:       3,
+		Checksum:       hex.EncodeToString(digest[:]),
+	}))
+
+	actual, err := os.ReadFile(filePath)
+	require.NoError(t, err)
+	assert.Equal(t, contents, actual)
+	assert.NoFileExists(t, filePath+downloadPartFileSuffix)
+	// Three segments and the retry of the failed segment.
+	assert.Len(t, ranges, 4)
+	for _, r := range ranges {
+		assert.NotEmpty(t, r)
+	}
+}
+
+func TestNewHTTPClient(t *testing.T) {
+	temporaryPath := t.TempDir()
+
+	// newCertificate returns a certificate that is signed by the given parent, or a self-signed certificate authority if there is no parent, and writes it and its key as PEM files.
+	newCertificate := func(t *testing.T, name string, parent *x509.Certificate, parentKey *ecdsa.PrivateKey) (certificate *x509.Certificate, key *ecdsa.PrivateKey, certificateFile string, keyFile string) {
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		require.NoError(t, err)
+
+		template := &x509.Certificate{
+			SerialNumber: big.NewInt(time.Now().UnixNano()),
+			Subject:      pkix.Name{CommonName: name},
+			NotBefore:    time.Now().Add(-time.Hour),
+			NotAfter:     time.Now().Add(time.Hour),
+			KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+			ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+			IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		}
+		if parent == nil {
+			template.IsCA = true
+			template.BasicConstraintsValid = true
+			parent = template
+			parentKey = key
+		}
+		data, err := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, parentKey)
+		require.NoError(t, err)
+		certificate, err = x509.ParseCertificate(data)
+		require.NoError(t, err)
+		keyData, err := x509.MarshalECPrivateKey(key)
+		require.NoError(t, err)
+
+		certificateFile = filepath.Join(temporaryPath, name+".crt")
+		require.NoError(t, os.WriteFile(certificateFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: data}), 0600))
+		keyFile = filepath.Join(temporaryPath, name+".key")
+		require.NoError(t, os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyData}), 0600))
+
+		return certificate, key, certificateFile, keyFile
+	}
+
+	ca, caKey, caFile, _ := newCertificate(t, "ca", nil, nil)
+	_, _, serverCertificateFile, serverKeyFile := newCertificate(t, "server", ca, caKey)
+	_, _, clientCertificateFile, clientKeyFile := newCertificate(t, "client", ca, caKey)
+
+	serverCertificate, err := tls.LoadX509KeyPair(serverCertificateFile, serverKeyFile)
+	require.NoError(t, err)
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(ca)
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(r.TLS.PeerCertificates[0].Subject.CommonName))
+	}))
+	server.TLS = &tls.Config{
+		Certificates: []tls.Certificate{serverCertificate},
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		ClientCAs:    clientCAs,
+	}
+	server.StartTLS()
+	defer server.Close()
+
+	t.Run("Default", func(t *testing.T) {
+		client, err := NewHTTPClient(HTTPClientOptions{})
+		require.NoError(t, err)
+
+		assert.NotNil(t, client.Transport.(*http.Transport).Proxy)
+		_, err = client.Get(server.URL)
+		assert.Error(t, err)
+	})
+
+	t.Run("Root CAs and client certificate", func(t *testing.T) {
+		for _, rootCA := range []string{caFile, temporaryPath} {
+			client, err := NewHTTPClient(HTTPClientOptions{
+				RootCAs:               []string{rootCA},
+				ClientCertificateFile: clientCertificateFile,
+				ClientKeyFile:         clientKeyFile,
+			})
+			require.NoError(t, err)
+
+			contents, err := downloadBytes(context.Background(), server.URL, DownloadOptions{
+				Client: client,
+			})
+			require.NoError(t, err)
+			assert.Equal(t, "client", string(contents))
+		}
+	})
+
+	t.Run("Invalid root CA", func(t *testing.T) {
+		_, err := NewHTTPClient(HTTPClientOptions{
+			RootCAs: []string{clientKeyFile},
+		})
+		assert.ErrorContains(t, err, "no certificates found")
+	})
+}
@@ -1,6 +1,7 @@
 package osutil
 
 import (
+	"bytes"
 	"io"
 	"os"
 )
@@ -70,3 +71,46 @@ func (s *StandardStream) Close() error {
 
 	return nil
 }
+
+// LineWriter calls a function for every line that is written to it.
+type LineWriter struct {
+	// handle is called for every line without its line ending.
+	handle func(line string)
+	// buffer holds the beginning of a line that has not been ended yet.
+	buffer []byte
+}
+
+var _ io.WriteCloser = (*LineWriter)(nil)
+
+// NewLineWriter returns a writer which calls the given function for every line that is written to it.
+func NewLineWriter(handle func(line string)) *LineWriter {
+	return &LineWriter{
+		handle: handle,
+	}
+}
+
+// Write writes the given data and calls the line function for every line that has been ended.
+func (w *LineWriter) Write(data []byte) (n int, err error) {
+	w.buffer = append(w.buffer, data...)
+	for {
+		i := bytes.IndexByte(w.buffer, '\n')
+		if i < 0 {
+			break
+		}
+
+		w.handle(string(bytes.TrimSuffix(w.buffer[:i], []byte{'\r'})))
+		w.buffer = w.buffer[i+1:]
+	}
+
+	return len(data), nil
+}
+
+// Close calls the line function with the last line if it has not been ended.
+func (w *LineWriter) Close() error {
+	if len(w.buffer) > 0 {
+		w.handle(string(w.buffer))
+		w.buffer = nil
+	}
+
+	return nil
+}
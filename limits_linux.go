@@ -4,7 +4,6 @@ package osutil
 
 import (
 	"bytes"
-	"errors"
 	"fmt"
 	"os"
 	"os/exec"
@@ -28,7 +27,7 @@ func EnforceProcessTreeLimits(limits ProcessTreeLimits) {
 
 	go func() {
 		for {
-			memoryUsageInKiB, err := getProcessTreeMemoryUsage()
+			memoryUsageInKiB, err := getProcessTreeMemoryUsage(os.Getpid())
 			if err != nil {
 				panic(fmt.Errorf("Failed to check memory usage: %w", err))
 			}
@@ -42,26 +41,95 @@ func EnforceProcessTreeLimits(limits ProcessTreeLimits) {
 	}()
 }
 
-// getProcessTreeMemoryUsage returns the total memory usage in KiB from the current process and all child processes.
+// enforceProcessTreeLimitsOfProcess constrains the given process and all its descendant processes to the specified limits until the returned function is called. If the limits are exceeded and no handler is defined, the given kill function is called.
+func enforceProcessTreeLimitsOfProcess(pid int, limits ProcessTreeLimits, kill func()) (stop func()) {
+	if limits.MaxMemoryInMiB <= 0 {
+		return func() {}
+	}
+
+	var watchdogInterval time.Duration
+	if limits.WatchdogInterval == 0 {
+		watchdogInterval = 2 * time.Second
+	} else {
+		watchdogInterval = limits.WatchdogInterval
+	}
+
+	stopWatchdog := make(chan struct{})
+	watchdogStopped := make(chan struct{})
+	go func() {
+		defer close(watchdogStopped)
+
+		ticker := time.NewTicker(watchdogInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-stopWatchdog:
+				return
+			case <-ticker.C:
+			}
+
+			memoryUsageInKiB, err := getProcessTreeMemoryUsage(pid)
+			if err != nil {
+				// The process has most likely exited already.
+				return
+			}
+
+			currentMemoryInMiB := memoryUsageInKiB / 1024
+			if currentMemoryInMiB > limits.MaxMemoryInMiB {
+				if limits.OnMemoryLimitReached != nil {
+					limits.OnMemoryLimitReached(currentMemoryInMiB, limits.MaxMemoryInMiB)
+				} else {
+					kill()
+
+					return
+				}
+			}
+		}
+	}()
+
+	return func() {
+		close(stopWatchdog)
+		<-watchdogStopped
+	}
+}
+
+// getProcessTreeMemoryUsage returns the total memory usage in KiB from the given process and all its descendant processes.
 //
 // REMARK This is currently a rough approximation. Memory shared between descendant processes is counted multiple times.
-func getProcessTreeMemoryUsage() (memoryUsageInKiB uint, err error) {
-	psCmd := exec.Command("ps", "-H", "-o", "rss=", strconv.Itoa(os.Getpid()))
+func getProcessTreeMemoryUsage(pid int) (memoryUsageInKiB uint, err error) {
+	psCmd := exec.Command("ps", "-e", "-o", "pid=,ppid=,rss=")
 	psOutput, err := psCmd.CombinedOutput()
 	if err != nil {
 		return 0, err
 	}
+
+	children := map[int][]int{}
+	rssOfProcess := map[int]uint{}
 	lines := bytes.Split(bytes.TrimSpace(psOutput), []byte("\n"))
 	for _, line := range lines {
-		line = bytes.TrimSpace(line)
-		if len(line) == 0 {
-			return 0, errors.New("Encountered empty line in \"ps\" output")
+		fields := bytes.Fields(line)
+		if len(fields) != 3 {
+			return 0, fmt.Errorf("Encountered unexpected line %q in \"ps\" output", line)
 		}
-		rss, err := strconv.Atoi(string(line))
-		if err != nil {
-			return 0, err
+		values := make([]int, len(fields))
+		for i, field := range fields {
+			if values[i], err = strconv.Atoi(string(field)); err != nil {
+				return 0, err
+			}
 		}
-		memoryUsageInKiB += uint(rss)
+		processPID, parentPID, rss := values[0], values[1], values[2]
+
+		children[parentPID] = append(children[parentPID], processPID)
+		rssOfProcess[processPID] = uint(rss)
+	}
+
+	if _, ok := rssOfProcess[pid]; !ok {
+		return 0, fmt.Errorf("process %d does not exist", pid)
+	}
+	for queue := []int{pid}; len(queue) > 0; queue = queue[1:] {
+		memoryUsageInKiB += rssOfProcess[queue[0]]
+		queue = append(queue, children[queue[0]]...)
 	}
 
 	return memoryUsageInKiB, nil
@@ -6,3 +6,9 @@ package osutil
 func EnforceProcessTreeLimits(limits ProcessTreeLimits) {
 	// WORKAROUND Implement this function for MacOS and Windows when it is actual needed. Until then we can cross-compile even if the function is only mentioned in a package. https://$INTERNAL/symflower/symflower/-/issues/3592
 }
+
+// enforceProcessTreeLimitsOfProcess constrains the given process and all its descendant processes to the specified limits until the returned function is called. If the limits are exceeded and no handler is defined, the given kill function is called.
+func enforceProcessTreeLimitsOfProcess(pid int, limits ProcessTreeLimits, kill func()) (stop func()) {
+	// WORKAROUND Implement this function for MacOS and Windows when it is actual needed. Until then we can cross-compile even if the function is only mentioned in a package. https://$INTERNAL/symflower/symflower/-/issues/3592
+	return func() {}
+}
@@ -0,0 +1,115 @@
+package osutil
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ErrShutdownRunning indicates that a hook is registered after the hooks of the shutdown registry have started running.
+var ErrShutdownRunning = errors.New("shutdown hooks are already running")
+
+// ShutdownRegistry holds hooks that clean up components when a program shuts down, e.g. flushing caches, removing temporary directories or stopping progress bars.
+type ShutdownRegistry struct {
+	lock sync.Mutex
+	// hooks holds the registered hooks in the order of their registration.
+	hooks []shutdownHook
+	// running is set when the hooks have started running, after which no hooks can be registered.
+	running bool
+
+	// runOnce makes sure the hooks are run only once.
+	runOnce sync.Once
+	// done is closed when all hooks have been run.
+	done chan struct{}
+	// err holds the aggregated errors of the hooks.
+	err error
+}
+
+// shutdownHook holds a registered shutdown hook.
+type shutdownHook struct {
+	name     string
+	priority int
+	timeout  time.Duration
+	hook     func(ctx context.Context) error
+}
+
+// NewShutdownRegistry returns an empty shutdown registry.
+func NewShutdownRegistry() *ShutdownRegistry {
+	return &ShutdownRegistry{
+		done: make(chan struct{}),
+	}
+}
+
+// Register registers a hook with the given name for error reports. Hooks with a higher priority run first, hooks with the same priority run in the order of their registration. If the timeout is not zero, the context of the hook is canceled after the timeout and the hook is not waited for any longer.
+// If the hooks have already started running, the hook is not registered and "ErrShutdownRunning" is returned, since it would never run.
+func (r *ShutdownRegistry) Register(name string, priority int, timeout time.Duration, hook func(ctx context.Context) error) (err error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if r.running {
+		return fmt.Errorf("cannot register shutdown hook %q: %w", name, ErrShutdownRunning)
+	}
+
+	r.hooks = append(r.hooks, shutdownHook{
+		name:     name,
+		priority: priority,
+		timeout:  timeout,
+		hook:     hook,
+	})
+
+	return nil
+}
+
+// Run runs all registered hooks in order and returns their aggregated errors. The hooks are run only once, further calls wait until the hooks have been run and return the same errors.
+func (r *ShutdownRegistry) Run(ctx context.Context) (err error) {
+	r.runOnce.Do(func() {
+		defer close(r.done)
+
+		r.lock.Lock()
+		r.running = true
+		hooks := append([]shutdownHook(nil), r.hooks...)
+		r.lock.Unlock()
+		sort.SliceStable(hooks, func(i, j int) bool {
+			return hooks[i].priority > hooks[j].priority
+		})
+
+		for _, hook := range hooks {
+			if e := hook.run(ctx); e != nil {
+				r.err = errors.Join(r.err, fmt.Errorf("shutdown hook %q: %w", hook.name, e))
+			}
+		}
+	})
+	<-r.done
+
+	return r.err
+}
+
+// run runs the hook within its timeout.
+func (h shutdownHook) run(ctx context.Context) (err error) {
+	if h.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, h.timeout)
+		defer cancel()
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				done <- fmt.Errorf("panic: %v", r)
+			}
+		}()
+
+		done <- h.hook(ctx)
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
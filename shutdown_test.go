@@ -0,0 +1,112 @@
+package osutil
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestShutdownRegistryRun(t *testing.T) {
+	registry := NewShutdownRegistry()
+
+	var order []string
+	var orderLock sync.Mutex
+	hook := func(name string, err error) func(ctx context.Context) error {
+		return func(ctx context.Context) error {
+			orderLock.Lock()
+			defer orderLock.Unlock()
+
+			order = append(order, name)
+
+			return err
+		}
+	}
+	require.NoError(t, registry.Register("progress bars", 0, 0, hook("progress bars", nil)))
+	require.NoError(t, registry.Register("caches", 10, 0, hook("caches", errors.New("cache is read-only"))))
+	require.NoError(t, registry.Register("temporary directories", 0, 0, hook("temporary directories", nil)))
+	release := make(chan struct{})
+	defer close(release)
+	require.NoError(t, registry.Register("hanging", 5, 10*time.Millisecond, func(ctx context.Context) error {
+		<-release
+
+		return nil
+	}))
+
+	err := registry.Run(context.Background())
+	require.Error(t, err)
+	assert.ErrorContains(t, err, `shutdown hook "caches": cache is read-only`)
+	assert.ErrorContains(t, err, `shutdown hook "hanging": context deadline exceeded`)
+	assert.Equal(t, []string{"caches", "progress bars", "temporary directories"}, order)
+
+	// Hooks run only once.
+	assert.Equal(t, err, registry.Run(context.Background()))
+	assert.Equal(t, []string{"caches", "progress bars", "temporary directories"}, order)
+}
+
+func TestShutdownRegistryInterrupt(t *testing.T) {
+	if IsWindows() {
+		t.Skip("Signals cannot be sent to the own process on Windows")
+	}
+
+	process, err := os.FindProcess(os.Getpid())
+	require.NoError(t, err)
+
+	registry := NewShutdownRegistry()
+	require.NoError(t, registry.Register("cleanup", 10, 0, func(ctx context.Context) error {
+		return errors.New("cleanup failed")
+	}))
+	require.NoError(t, registry.Register("hanging", 0, 0, func(ctx context.Context) error {
+		<-ctx.Done()
+
+		return ctx.Err()
+	}))
+	var log bytes.Buffer
+	var logLock sync.Mutex
+	exited := make(chan struct{})
+	ctx, cancel := ContextWithInterruptWithOptions(context.Background(), InterruptOptions{
+		Signals:   []os.Signal{syscall.SIGTERM},
+		LogWriter: &synchronizedWriter{lock: &logLock, writer: &log},
+
+		// The grace period also bounds the shutdown hooks.
+		GracePeriod: 50 * time.Millisecond,
+		Shutdown:    registry,
+
+		Exit: func() {
+			close(exited)
+		},
+	})
+	defer cancel()
+
+	require.NoError(t, process.Signal(syscall.SIGTERM))
+	<-ctx.Done()
+	select {
+	case <-exited:
+	case <-time.After(5 * time.Second):
+		require.Fail(t, "program has not been exited")
+	}
+
+	err = registry.Run(context.Background())
+	assert.ErrorContains(t, err, `shutdown hook "cleanup": cleanup failed`)
+	assert.ErrorContains(t, err, `shutdown hook "hanging": context deadline exceeded`)
+	// The errors are logged before the program is exited.
+	logLock.Lock()
+	defer logLock.Unlock()
+	assert.Equal(t, err.Error()+"\n", log.String())
+}
+
+func TestShutdownRegistryRegisterAfterRun(t *testing.T) {
+	registry := NewShutdownRegistry()
+	require.NoError(t, registry.Run(context.Background()))
+
+	assert.ErrorIs(t, registry.Register("cleanup", 0, 0, func(ctx context.Context) error {
+		return nil
+	}), ErrShutdownRunning)
+}
@@ -0,0 +1,175 @@
+package osutil
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"sort"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// SignalForwarderOptions holds options for forwarding signals to child processes.
+type SignalForwarderOptions struct {
+	// Signals holds the signals that are forwarded. Defaults to "SIGINT", "SIGTERM" and "SIGHUP".
+	Signals []os.Signal
+	// KillTimeout holds the duration after a forwarded signal after which remaining processes are killed with "SIGKILL". Defaults to 10 seconds.
+	KillTimeout time.Duration
+	// OnForceKilled is called with the process IDs of the processes and process groups that had to be killed after received signals have been forwarded.
+	OnForceKilled func(pids []int)
+}
+
+// SignalForwarder forwards signals to registered child processes and process groups.
+// Registered processes must be waited for by their owner, e.g. with "exec.Cmd.Wait", since they are otherwise still present after they have terminated.
+type SignalForwarder struct {
+	options SignalForwarderOptions
+
+	lock sync.Mutex
+	// targets holds the registered process IDs and if they identify a process group.
+	targets map[int]bool
+	// forceKilled holds the process IDs that have been killed after received signals have been forwarded.
+	forceKilled []int
+}
+
+// NewSignalForwarder returns a signal forwarder with the given options.
+func NewSignalForwarder(options SignalForwarderOptions) *SignalForwarder {
+	if len(options.Signals) == 0 {
+		options.Signals = []os.Signal{os.Interrupt, syscall.SIGTERM, syscall.SIGHUP}
+	}
+	if options.KillTimeout <= 0 {
+		options.KillTimeout = 10 * time.Second
+	}
+
+	return &SignalForwarder{
+		options: options,
+
+		targets: map[int]bool{},
+	}
+}
+
+// AddProcess registers a process to forward signals to.
+func (f *SignalForwarder) AddProcess(pid int) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	f.targets[pid] = false
+}
+
+// AddProcessGroup registers a process group, e.g. of a command that is executed with "CommandExecute", to forward signals to.
+func (f *SignalForwarder) AddProcessGroup(pgid int) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	f.targets[pgid] = true
+}
+
+// Remove unregisters a process or process group.
+func (f *SignalForwarder) Remove(pid int) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	delete(f.targets, pid)
+}
+
+// Start forwards the signals of the options that are received by the program until the returned function is called.
+func (f *SignalForwarder) Start() (stop func()) {
+	c := make(chan os.Signal, 10)
+	signal.Notify(c, f.options.Signals...)
+
+	done := make(chan struct{})
+	var stopOnce sync.Once
+	go func() {
+		for {
+			select {
+			case <-done:
+				return
+			case s := <-c:
+				go func() {
+					forceKilled, _ := f.Terminate(s, f.options.KillTimeout)
+					if len(forceKilled) > 0 && f.options.OnForceKilled != nil {
+						f.options.OnForceKilled(forceKilled)
+					}
+				}()
+			}
+		}
+	}()
+
+	return func() {
+		stopOnce.Do(func() {
+			signal.Stop(c)
+			close(done)
+		})
+	}
+}
+
+// Forward sends the given signal to all registered processes and process groups.
+func (f *SignalForwarder) Forward(s os.Signal) (err error) {
+	for pid, group := range f.targetsCopy() {
+		if e := signalProcess(pid, group, s); e != nil {
+			err = errors.Join(err, fmt.Errorf("cannot forward signal %q to process %d: %w", s, pid, e))
+		}
+	}
+
+	return err
+}
+
+// Terminate sends the given signal to all registered processes and process groups and kills those with "SIGKILL" that did not terminate within the given timeout. The process IDs of killed processes and process groups are returned.
+func (f *SignalForwarder) Terminate(s os.Signal, timeout time.Duration) (forceKilled []int, err error) {
+	err = f.Forward(s)
+
+	deadline := time.Now().Add(timeout)
+	for {
+		remaining := map[int]bool{}
+		for pid, group := range f.targetsCopy() {
+			if processRunning(pid, group) {
+				remaining[pid] = group
+			}
+		}
+		if len(remaining) == 0 {
+			return nil, err
+		}
+
+		if time.Now().After(deadline) {
+			for pid, group := range remaining {
+				if e := signalProcess(pid, group, os.Kill); e != nil {
+					err = errors.Join(err, fmt.Errorf("cannot kill process %d: %w", pid, e))
+
+					continue
+				}
+				forceKilled = append(forceKilled, pid)
+			}
+			sort.Ints(forceKilled)
+
+			f.lock.Lock()
+			f.forceKilled = append(f.forceKilled, forceKilled...)
+			f.lock.Unlock()
+
+			return forceKilled, err
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// ForceKilled returns the process IDs of the processes and process groups that have been killed with "SIGKILL" so far.
+func (f *SignalForwarder) ForceKilled() (pids []int) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	return append([]int(nil), f.forceKilled...)
+}
+
+// targetsCopy returns a copy of the registered process IDs.
+func (f *SignalForwarder) targetsCopy() (targets map[int]bool) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	targets = make(map[int]bool, len(f.targets))
+	for pid, group := range f.targets {
+		targets[pid] = group
+	}
+
+	return targets
+}
@@ -0,0 +1,39 @@
+//go:build !windows
+
+package osutil
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"syscall"
+)
+
+// signalProcess sends the given signal to the given process or process group. Processes that do not exist anymore are ignored.
+func signalProcess(pid int, group bool, s os.Signal) error {
+	signal, ok := s.(syscall.Signal)
+	if !ok {
+		return fmt.Errorf("unsupported signal %q", s)
+	}
+	if group {
+		pid = -pid
+	}
+
+	if err := syscall.Kill(pid, signal); err != nil && !errors.Is(err, syscall.ESRCH) {
+		return err
+	}
+
+	return nil
+}
+
+// processRunning returns if the given process or process group still exists.
+func processRunning(pid int, group bool) bool {
+	if group {
+		pid = -pid
+	}
+
+	// Processes of other users cannot be signaled but exist nevertheless.
+	err := syscall.Kill(pid, 0)
+
+	return err == nil || errors.Is(err, syscall.EPERM)
+}
@@ -0,0 +1,89 @@
+package osutil
+
+import (
+	"os"
+	"os/exec"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSignalForwarderTerminate(t *testing.T) {
+	if IsWindows() {
+		t.Skip("Signals other than killing are not supported on Windows")
+	}
+
+	start := func(t *testing.T, script string) (cmd *exec.Cmd, done chan struct{}) {
+		cmd = exec.Command("sh", "-c", script)
+		commandSetProcessGroup(cmd)
+		require.NoError(t, cmd.Start())
+
+		done = make(chan struct{})
+		go func() {
+			_ = cmd.Wait()
+			close(done)
+		}()
+
+		return cmd, done
+	}
+
+	terminating, terminatingDone := start(t, "exec sleep 30")
+	ignoring, ignoringDone := start(t, `trap "" TERM; exec sleep 30`)
+	// Give the shell time to ignore the signal.
+	time.Sleep(200 * time.Millisecond)
+
+	forwarder := NewSignalForwarder(SignalForwarderOptions{})
+	forwarder.AddProcessGroup(terminating.Process.Pid)
+	forwarder.AddProcessGroup(ignoring.Process.Pid)
+
+	forceKilled, err := forwarder.Terminate(syscall.SIGTERM, 500*time.Millisecond)
+	require.NoError(t, err)
+	assert.Equal(t, []int{ignoring.Process.Pid}, forceKilled)
+	assert.Equal(t, []int{ignoring.Process.Pid}, forwarder.ForceKilled())
+
+	for _, done := range []chan struct{}{terminatingDone, ignoringDone} {
+		select {
+		case <-done:
+		case <-time.After(5 * time.Second):
+			require.Fail(t, "process has not been terminated")
+		}
+	}
+	assert.Equal(t, syscall.SIGTERM, commandSignal(terminating.ProcessState))
+	assert.Equal(t, syscall.SIGKILL, commandSignal(ignoring.ProcessState))
+}
+
+func TestSignalForwarderStart(t *testing.T) {
+	if IsWindows() {
+		t.Skip("Signals cannot be sent to the own process on Windows")
+	}
+
+	cmd := exec.Command("sleep", "30")
+	commandSetProcessGroup(cmd)
+	require.NoError(t, cmd.Start())
+	done := make(chan struct{})
+	go func() {
+		_ = cmd.Wait()
+		close(done)
+	}()
+
+	forwarder := NewSignalForwarder(SignalForwarderOptions{
+		Signals: []os.Signal{syscall.SIGHUP},
+	})
+	forwarder.AddProcessGroup(cmd.Process.Pid)
+	stop := forwarder.Start()
+	defer stop()
+
+	process, err := os.FindProcess(os.Getpid())
+	require.NoError(t, err)
+	require.NoError(t, process.Signal(syscall.SIGHUP))
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		require.Fail(t, "signal has not been forwarded")
+	}
+	assert.Equal(t, syscall.SIGHUP, commandSignal(cmd.ProcessState))
+}
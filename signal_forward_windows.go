@@ -0,0 +1,46 @@
+package osutil
+
+import (
+	"errors"
+	"os"
+
+	"golang.org/x/sys/windows"
+)
+
+// windowsProcessStillActive holds the exit code of a process that has not exited yet, which is "STILL_ACTIVE" in the Windows API.
+const windowsProcessStillActive = 259
+
+// signalProcess sends the given signal to the given process or process group. Processes that do not exist anymore are ignored.
+func signalProcess(pid int, group bool, s os.Signal) error {
+	// WORKAROUND Windows has neither process groups that can be signaled nor signals other than killing, so only the process itself is killed.
+	if s != os.Kill {
+		return nil
+	}
+
+	process, err := os.FindProcess(pid)
+	if err != nil {
+		return nil
+	}
+
+	return process.Kill()
+}
+
+// processRunning returns if the given process or process group still exists.
+func processRunning(pid int, group bool) bool {
+	// REMARK Opening a process succeeds as long as its handle is referenced, even if the process has exited already. Hence, the exit code has to be checked as well.
+	handle, err := windows.OpenProcess(windows.PROCESS_QUERY_LIMITED_INFORMATION, false, uint32(pid))
+	if err != nil {
+		// Processes of other users cannot be opened but exist nevertheless.
+		return errors.Is(err, windows.ERROR_ACCESS_DENIED)
+	}
+	defer func() {
+		_ = windows.CloseHandle(handle)
+	}()
+
+	var exitCode uint32
+	if err := windows.GetExitCodeProcess(handle, &exitCode); err != nil {
+		return false
+	}
+
+	return exitCode == windowsProcessStillActive
+}